@@ -0,0 +1,98 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Canonicalize returns the canonical form of a URL, which is used to
+// detect URLs that point to the same page. The host is lowercased, the
+// default port and the fragment are removed and the query parameters
+// are sorted. Query parameters matching any of the stripParams patterns,
+// like "utm_*", are removed. Invalid URLs are returned unchanged.
+func Canonicalize(rawurl string, stripParams []string) string {
+	rawurl = strings.TrimSpace(rawurl)
+
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Opaque != "" {
+		return rawurl
+	}
+
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+	if u.Host != "" && u.Path == "" {
+		u.Path = "/"
+	}
+
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.ForceQuery = false
+	u.RawQuery = canonicalQuery(u.RawQuery, stripParams)
+
+	return u.String()
+}
+
+// key returns the key of a job in the visited set. Requests other than
+// plain GET requests are told apart by their method and body as well.
+func (s *Scraper) key(t target) string {
+	url := s.Canonicalize(t.url)
+	if (t.method == "" || t.method == http.MethodGet) && len(t.body) == 0 {
+		return url
+	}
+
+	sum := sha256.Sum256(t.body)
+	return t.method + " " + url + " " + hex.EncodeToString(sum[:])
+}
+
+func canonicalQuery(query string, stripParams []string) string {
+	if query == "" {
+		return ""
+	}
+
+	params := strings.Split(query, "&")
+	kept := params[:0]
+	for _, param := range params {
+		if param == "" || stripParam(param, stripParams) {
+			continue
+		}
+		kept = append(kept, param)
+	}
+
+	// Values of the same parameter keep their order, as it may matter.
+	sort.SliceStable(kept, func(i, j int) bool {
+		return paramName(kept[i]) < paramName(kept[j])
+	})
+
+	return strings.Join(kept, "&")
+}
+
+func stripParam(param string, patterns []string) bool {
+	name, err := url.QueryUnescape(paramName(param))
+	if err != nil {
+		return false
+	}
+	name = strings.ToLower(name)
+
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func paramName(param string) string {
+	name, _, _ := strings.Cut(param, "=")
+	return name
+}
@@ -0,0 +1,45 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape_test
+
+import (
+	"testing"
+
+	"github.com/philippta/flyscrape"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		url    string
+		strip  []string
+		result string
+	}{
+		{url: "https://A.com/x#frag", result: "https://a.com/x"},
+		{url: "https://a.com", result: "https://a.com/"},
+		{url: "https://a.com:443/x", result: "https://a.com/x"},
+		{url: "http://a.com:80/x", result: "http://a.com/x"},
+		{url: "http://a.com:8080/x", result: "http://a.com:8080/x"},
+		{url: "https://a.com/x?b=1&a=2", result: "https://a.com/x?a=2&b=1"},
+		{url: "https://a.com/x?a=2&b=1", result: "https://a.com/x?a=2&b=1"},
+		{url: "https://a.com/x?a=2&a=1", result: "https://a.com/x?a=2&a=1"},
+		{url: "https://a.com/x?", result: "https://a.com/x"},
+		{url: "https://a.com/x?q=a%20b", result: "https://a.com/x?q=a%20b"},
+		{
+			url:    "https://a.com/x?utm_source=news&id=1&SessionID=abc",
+			strip:  []string{"utm_*", "sessionid"},
+			result: "https://a.com/x?id=1",
+		},
+		{url: " https://a.com/x ", result: "https://a.com/x"},
+		{url: "mailto:foo@example.com", result: "mailto:foo@example.com"},
+		{url: "://invalid", result: "://invalid"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			require.Equal(t, test.result, flyscrape.Canonicalize(test.url, test.strip))
+		})
+	}
+}
@@ -19,6 +19,9 @@ var arrayFields = []string{
 	"allowedURLs",
 	"blockedURLs",
 	"proxies",
+	"stripParams",
+	"sitemaps",
+	"sitemapURLs",
 }
 
 func parseConfigArgs(args []string) (map[string]any, error) {
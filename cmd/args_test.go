@@ -41,6 +41,14 @@ func TestParseConfigUpdates(t *testing.T) {
 			flags:   `--foo a --foo=b`,
 			updates: map[string]any{"foo": []any{"a", "b"}},
 		},
+		{
+			flags:   `--sitemaps a --sitemapURLs b`,
+			updates: map[string]any{"sitemaps": []any{"a"}, "sitemapURLs": []any{"b"}},
+		},
+		{
+			flags:   `--stripParams utm_*`,
+			updates: map[string]any{"stripParams": []any{"utm_*"}},
+		},
 		{
 			flags:   `--foo 69`,
 			updates: map[string]any{"foo": 69},
@@ -16,6 +16,7 @@ type DevCommand struct{}
 func (c *DevCommand) Run(args []string) error {
 	fs := flag.NewFlagSet("flyscrape-dev", flag.ContinueOnError)
 	fs.Usage = c.Usage
+	setupLogging := logFlags(fs)
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -24,6 +25,10 @@ func (c *DevCommand) Run(args []string) error {
 		return flag.ErrHelp
 	}
 
+	if err := setupLogging(); err != nil {
+		return err
+	}
+
 	cfg, err := parseConfigArgs(fs.Args()[1:])
 	if err != nil {
 		return fmt.Errorf("error parsing config flags: %w", err)
@@ -39,7 +44,16 @@ Recursive scraping is disabled in this mode, only the initial URL will be scrape
 
 Usage:
 
-    flyscrape dev SCRIPT [config flags]
+    flyscrape dev [flags] SCRIPT [config flags]
+
+Flags:
+
+    --log-level LEVEL
+                log messages of at least LEVEL: debug, info (default),
+                warn or error
+
+    --log-format FORMAT
+                log messages as text (default) or json
 
 Examples:
 
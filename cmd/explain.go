@@ -0,0 +1,98 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/philippta/flyscrape"
+)
+
+type ExplainCommand struct{}
+
+func (c *ExplainCommand) Run(args []string) error {
+	fs := flag.NewFlagSet("flyscrape-explain", flag.ContinueOnError)
+	fs.Usage = c.Usage
+	depth := fs.Int("at-depth", 1, "")
+	setupLogging := logFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if fs.NArg() < 2 || fs.Arg(0) == "" || fs.Arg(1) == "" {
+		c.Usage()
+		return flag.ErrHelp
+	}
+
+	if err := setupLogging(); err != nil {
+		return err
+	}
+
+	cfg, err := parseConfigArgs(fs.Args()[2:])
+	if err != nil {
+		return fmt.Errorf("error parsing config flags: %w", err)
+	}
+
+	verdicts, err := flyscrape.Explain(fs.Arg(0), fs.Arg(1), *depth, cfg)
+	if err != nil {
+		return err
+	}
+
+	slices.SortFunc(verdicts, func(a, b flyscrape.Verdict) int {
+		return strings.Compare(a.Module, b.Module)
+	})
+
+	allowed := true
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	for _, v := range verdicts {
+		if v.OK {
+			fmt.Fprintf(w, "%s\tallowed\n", v.Module)
+			continue
+		}
+		allowed = false
+		fmt.Fprintf(w, "%s\trejected: %s\n", v.Module, v.Reason)
+	}
+	w.Flush()
+
+	fmt.Println()
+	if allowed {
+		fmt.Println("The URL would be requested.")
+	} else {
+		fmt.Println("The URL would not be requested.")
+	}
+	return nil
+}
+
+func (c *ExplainCommand) Usage() {
+	fmt.Println(`
+The explain command shows whether the modules of the scraping script would
+request a URL and why, without requesting it.
+
+Usage:
+
+    flyscrape explain [flags] SCRIPT URL [config flags]
+
+Flags:
+
+    --at-depth N
+                explain the URL as found at depth N, where the start
+                URLs are at depth 0 (default 1)
+
+Examples:
+
+    # Explain why a URL is not scraped.
+    $ flyscrape explain example.js "http://example.com/page"
+
+    # Explain a start URL.
+    $ flyscrape explain --at-depth 0 example.js "http://example.com/"
+
+    # Explain a URL with a different config.
+    $ flyscrape explain example.js "http://example.com/page" --depth 2
+`[1:])
+}
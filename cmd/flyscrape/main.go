@@ -7,6 +7,7 @@ package main
 import (
 	_ "embed"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 
@@ -14,6 +15,7 @@ import (
 	_ "github.com/philippta/flyscrape/modules/browser"
 	_ "github.com/philippta/flyscrape/modules/cache"
 	_ "github.com/philippta/flyscrape/modules/cookies"
+	_ "github.com/philippta/flyscrape/modules/dedup"
 	_ "github.com/philippta/flyscrape/modules/depth"
 	_ "github.com/philippta/flyscrape/modules/domainfilter"
 	_ "github.com/philippta/flyscrape/modules/followlinks"
@@ -23,6 +25,8 @@ import (
 	_ "github.com/philippta/flyscrape/modules/proxy"
 	_ "github.com/philippta/flyscrape/modules/ratelimit"
 	_ "github.com/philippta/flyscrape/modules/retry"
+	_ "github.com/philippta/flyscrape/modules/robots"
+	_ "github.com/philippta/flyscrape/modules/sitemap"
 	_ "github.com/philippta/flyscrape/modules/starturl"
 	_ "github.com/philippta/flyscrape/modules/urlfilter"
 )
@@ -32,7 +36,7 @@ func main() {
 
 	if err := (&cmd.Main{}).Run(os.Args[1:]); err != nil {
 		if err != flag.ErrHelp {
-			log.Println(err)
+			fmt.Fprintln(os.Stderr, err)
 		}
 		os.Exit(1)
 	}
@@ -11,6 +11,8 @@ import (
 	"log"
 	"os"
 	"strings"
+
+	"github.com/philippta/flyscrape"
 )
 
 func main() {
@@ -20,7 +22,7 @@ func main() {
 	if err := m.Run(os.Args[1:]); err == flag.ErrHelp {
 		os.Exit(1)
 	} else if err != nil {
-		log.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
@@ -40,6 +42,8 @@ func (m *Main) Run(args []string) error {
 		return (&RunCommand{}).Run(args)
 	case "dev":
 		return (&DevCommand{}).Run(args)
+	case "explain":
+		return (&ExplainCommand{}).Run(args)
 	case "version":
 		return (&VersionCommand{}).Run(args)
 	default:
@@ -51,6 +55,17 @@ func (m *Main) Run(args []string) error {
 	}
 }
 
+// logFlags registers the logging flags and returns a function that sets
+// up logging once the flags are parsed.
+func logFlags(fs *flag.FlagSet) func() error {
+	level := fs.String("log-level", "info", "")
+	format := fs.String("log-format", flyscrape.LogFormatText, "")
+
+	return func() error {
+		return flyscrape.SetupLogging(*level, *format)
+	}
+}
+
 func (m *Main) Usage() {
 	fmt.Println(`
 flyscrape is a standalone and scriptable web scraper for efficiently extracting data from websites.
@@ -64,6 +79,7 @@ Commands:
     new       creates a sample scraping script
     run       runs a scraping script
     dev       watches and re-runs a scraping script
+    explain   shows whether and why a URL would be scraped
     version   prints the version
 `[1:])
 }
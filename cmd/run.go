@@ -16,6 +16,11 @@ type RunCommand struct{}
 func (c *RunCommand) Run(args []string) error {
 	fs := flag.NewFlagSet("flyscrape-run", flag.ContinueOnError)
 	fs.Usage = c.Usage
+	resume := fs.Bool("resume", false, "")
+	stats := fs.String("stats", "", "")
+	progress := fs.Bool("progress", false, "")
+	metricsAddr := fs.String("metrics-addr", "", "")
+	setupLogging := logFlags(fs)
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -24,12 +29,21 @@ func (c *RunCommand) Run(args []string) error {
 		return flag.ErrHelp
 	}
 
+	if err := setupLogging(); err != nil {
+		return err
+	}
+
 	cfg, err := parseConfigArgs(fs.Args()[1:])
 	if err != nil {
 		return fmt.Errorf("error parsing config flags: %w", err)
 	}
 
-	return flyscrape.Run(fs.Arg(0), cfg)
+	return flyscrape.Run(fs.Arg(0), cfg, flyscrape.RunOptions{
+		Resume:      *resume,
+		Stats:       *stats,
+		Progress:    *progress,
+		MetricsAddr: *metricsAddr,
+	})
 }
 
 func (c *RunCommand) Usage() {
@@ -38,7 +52,25 @@ The run command runs the scraping script.
 
 Usage:
 
-    flyscrape run SCRIPT [config flags]
+    flyscrape run [flags] SCRIPT [config flags]
+
+Flags:
+
+    --resume    record the progress in SCRIPT.state and continue
+                from it if a previous run was interrupted
+    --stats     write the summary of the run as JSON to a file
+    --progress  show the progress of the run, if stderr is a terminal
+
+    --metrics-addr ADDR
+                serve Prometheus metrics on /metrics and the status
+                of the run on /status while it is going
+
+    --log-level LEVEL
+                log messages of at least LEVEL: debug, info (default),
+                warn or error
+
+    --log-format FORMAT
+                log messages as text (default) or json
 
 Examples:
 
@@ -48,6 +80,9 @@ Examples:
     # Set the URL as argument.
     $ flyscrape run example.js --url "http://other.com"
 
+    # Read the URLs from stdin.
+    $ cat urls.txt | flyscrape run example.js --urls -
+
     # Enable proxy support.
     $ flyscrape run example.js --proxies "http://someproxy:8043"
 
@@ -59,5 +94,17 @@ Examples:
 
     # Write the output to a file.
     $ flyscrape run example.js --output.file results.json
+
+    # Continue a crawl that was interrupted.
+    $ flyscrape run --resume example.js
+
+    # Write the summary of the run to a file.
+    $ flyscrape run --stats stats.json example.js
+
+    # Serve metrics on port 9090.
+    $ flyscrape run --metrics-addr :9090 example.js
+
+    # Log why URLs are skipped.
+    $ flyscrape run --log-level debug example.js
 `[1:])
 }
@@ -0,0 +1,241 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ConfigError is a config value that could not be decoded, like
+// {depth: "5"}.
+type ConfigError struct {
+	// Key is the path of the value, like "depth" or "output.format".
+	Key string
+
+	// Expected and Got are the JSON types of the value, like "number"
+	// and "string". They are empty if Err is set instead.
+	Expected string
+	Got      string
+
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Key, e.Err)
+	}
+	return fmt.Sprintf("%s: expected %s, got %s", e.Key, e.Expected, e.Got)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigErrors lists all problems of a config.
+type ConfigErrors []*ConfigError
+
+func (errs ConfigErrors) Error() string {
+	var b strings.Builder
+	b.WriteString("invalid config:")
+	for _, err := range errs {
+		b.WriteString("\n  ")
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// loadConfig creates the modules and the scraper options from the
+// config. All problems are returned at once and unknown keys are
+// logged as warnings.
+func loadConfig(cfg Config) ([]Module, Options, error) {
+	var errs ConfigErrors
+
+	mods, err := LoadModules(cfg)
+	if err != nil && !errors.As(err, &errs) {
+		return nil, Options{}, err
+	}
+
+	var opts Options
+	errs = append(errs, decodeConfig(cfg, &opts)...)
+	if len(errs) > 0 {
+		slices.SortStableFunc(errs, func(a, b *ConfigError) int {
+			return strings.Compare(a.Key, b.Key)
+		})
+		return nil, Options{}, errs
+	}
+
+	known := configKeys(&opts)
+	for _, mod := range mods {
+		known = append(known, configKeys(mod)...)
+	}
+	for _, key := range unknownKeys(cfg, known) {
+		if s := suggestKey(key, known); s != "" {
+			slog.Warn("unknown config key", "key", key, "suggestion", s)
+		} else {
+			slog.Warn("unknown config key", "key", key)
+		}
+	}
+
+	return mods, opts, nil
+}
+
+// decodeConfig decodes the config into v key by key, so that all
+// invalid values are reported instead of only the first one.
+func decodeConfig(cfg Config, v any) []*ConfigError {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(cfg, &values); err != nil {
+		return []*ConfigError{{Key: "config", Err: err}}
+	}
+
+	known := configKeys(v)
+
+	var errs []*ConfigError
+	for _, key := range sortedKeys(values) {
+		if !containsFold(known, key) {
+			continue
+		}
+
+		b, _ := json.Marshal(map[string]json.RawMessage{key: values[key]})
+		if err := json.Unmarshal(b, v); err != nil {
+			errs = append(errs, configError(key, err))
+		}
+	}
+	return errs
+}
+
+func configError(key string, err error) *ConfigError {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		return &ConfigError{Key: key, Err: err}
+	}
+
+	if typeErr.Field != "" {
+		key = typeErr.Field
+	}
+
+	got := typeErr.Value
+	if got == "bool" {
+		got = "boolean"
+	}
+
+	return &ConfigError{Key: key, Expected: jsonType(typeErr.Type), Got: got}
+}
+
+// jsonType returns the name of the JSON type that decodes into t.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return t.String()
+}
+
+// configKeys returns the keys of the config that are decoded into v.
+func configKeys(v any) []string {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var keys []string
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Type.Kind() == reflect.Func {
+			continue
+		}
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				continue // its fields are visible fields themselves
+			}
+			name = f.Name
+		}
+		keys = append(keys, name)
+	}
+	return keys
+}
+
+// unknownKeys returns the keys of the config that are not known.
+// Like encoding/json, keys are matched case-insensitively.
+func unknownKeys(cfg Config, known []string) []string {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(cfg, &values); err != nil {
+		return nil
+	}
+
+	var unknown []string
+	for _, key := range sortedKeys(values) {
+		if !containsFold(known, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	return unknown
+}
+
+// suggestKey returns the known key that is closest to the unknown one,
+// or an empty string if none is close enough to be a typo.
+func suggestKey(key string, known []string) string {
+	best, bestDist := "", 3
+	for _, k := range known {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(k)); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance of a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func containsFold(keys []string, key string) bool {
+	return slices.ContainsFunc(keys, func(k string) bool {
+		return strings.EqualFold(k, key)
+	})
+}
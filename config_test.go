@@ -0,0 +1,77 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/philippta/flyscrape"
+	_ "github.com/philippta/flyscrape/modules/depth"
+	_ "github.com/philippta/flyscrape/modules/followlinks"
+	_ "github.com/philippta/flyscrape/modules/starturl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadModulesConfigErrors(t *testing.T) {
+	_, err := flyscrape.LoadModules(flyscrape.Config(`{"depth": "5", "follow": 3, "url": 1}`))
+
+	var errs flyscrape.ConfigErrors
+	require.True(t, errors.As(err, &errs))
+	require.ElementsMatch(t, flyscrape.ConfigErrors{
+		{Key: "depth", Expected: "integer", Got: "string"},
+		{Key: "follow", Expected: "array", Got: "number"},
+		{Key: "url", Expected: "string", Got: "number"},
+	}, errs)
+}
+
+func TestRunnerConfigErrors(t *testing.T) {
+	runner, err := flyscrape.New(`
+		export const config = { url: "http://www.example.com/", depth: "5", maxDuration: true };
+		export default function() {}
+	`)
+	require.NoError(t, err)
+
+	_, err = runner.Run(context.Background())
+	require.EqualError(t, err, "invalid config:\n"+
+		"  depth: expected integer, got string\n"+
+		"  maxDuration: expected duration string or number of seconds")
+}
+
+func TestRunnerUnknownConfigKeys(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+
+	client := &http.Client{Transport: flyscrape.MockTransport(200, "")}
+
+	runner, err := flyscrape.New(`
+		export const config = { url: "http://www.example.com/", dept: 1, workers: 2, somethingElse: true };
+		export default function() {}
+	`, flyscrape.WithClient(client))
+	require.NoError(t, err)
+
+	results, err := runner.Run(context.Background())
+	require.NoError(t, err)
+	for range results {
+	}
+
+	require.Contains(t, buf.String(), `level=WARN msg="unknown config key" key=dept suggestion=depth`)
+	require.Contains(t, buf.String(), `level=WARN msg="unknown config key" key=somethingElse`+"\n")
+	require.NotContains(t, buf.String(), "key=workers")
+	require.NotContains(t, buf.String(), "key=url")
+}
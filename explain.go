@@ -0,0 +1,67 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/cornelk/hashmap"
+)
+
+// Verdict is the decision of a module about a request.
+type Verdict struct {
+	Module string
+	OK     bool
+	Reason string
+}
+
+// Explain asks every module that validates requests whether it would let
+// a request to the URL at the given depth through, without sending it.
+// Only these modules are provisioned.
+func (s *Scraper) Explain(url string, depth int) ([]Verdict, error) {
+	s.initFrontier()
+	defer s.jobs.close()
+	s.visited = hashmap.New[string, struct{}]()
+	s.parked = map[string]*parked{}
+	s.domainPages = map[string]int{}
+	s.hosts = map[string]*HostProgress{}
+	s.initClient()
+
+	request := &Request{
+		Method:  http.MethodGet,
+		URL:     url,
+		Headers: http.Header{},
+		Cookies: s.Client.Jar,
+		Depth:   depth,
+	}
+
+	var validators []Module
+	for _, mod := range s.Modules {
+		if _, ok := mod.(RequestValidator); !ok {
+			continue
+		}
+		if v, ok := mod.(Provisioner); ok {
+			if err := v.Provision(s); err != nil {
+				s.finalize(validators)
+				return nil, fmt.Errorf("%s: %w", mod.ModuleInfo().ID, err)
+			}
+		}
+		validators = append(validators, mod)
+	}
+
+	var verdicts []Verdict
+	for _, mod := range validators {
+		ok, reason := mod.(RequestValidator).ValidateRequest(request)
+		verdicts = append(verdicts, Verdict{
+			Module: mod.ModuleInfo().ID,
+			OK:     ok,
+			Reason: reason,
+		})
+	}
+
+	s.finalize(validators)
+	return verdicts, nil
+}
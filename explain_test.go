@@ -0,0 +1,52 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape_test
+
+import (
+	"testing"
+
+	"github.com/philippta/flyscrape"
+	"github.com/philippta/flyscrape/modules/depth"
+	"github.com/philippta/flyscrape/modules/domainfilter"
+	"github.com/philippta/flyscrape/modules/hook"
+	"github.com/philippta/flyscrape/modules/urlfilter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	var provisioned bool
+
+	scraper := flyscrape.NewScraper()
+	scraper.Modules = []flyscrape.Module{
+		&depth.Module{Depth: 1},
+		&domainfilter.Module{URL: "http://www.example.com/", BlockedDomains: []string{"blocked.example.com"}},
+		&urlfilter.Module{URL: "http://www.example.com/", BlockedURLs: []string{`/private`}},
+		hook.Module{
+			ProvisionFn: func(flyscrape.Context) {
+				provisioned = true
+			},
+		},
+	}
+
+	verdicts, err := scraper.Explain("http://www.example.com/page", 1)
+	require.NoError(t, err)
+	require.Equal(t, []flyscrape.Verdict{
+		{Module: "depth", OK: true},
+		{Module: "domainfilter", OK: true},
+		{Module: "urlfilter", OK: true},
+		{Module: "hook", OK: true},
+	}, verdicts)
+
+	verdicts, err = scraper.Explain("http://blocked.example.com/private", 2)
+	require.NoError(t, err)
+	require.Equal(t, []flyscrape.Verdict{
+		{Module: "depth", Reason: "depth 2 exceeds the maximum depth of 1"},
+		{Module: "domainfilter", Reason: `domain "blocked.example.com" is blocked`},
+		{Module: "urlfilter", Reason: `url matches blocked pattern "/private"`},
+		{Module: "hook", OK: true},
+	}, verdicts)
+
+	require.True(t, provisioned)
+}
@@ -0,0 +1,238 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Defines fetch and the classes around it. It is called with the native
+// function that sends a request and one that decodes an ArrayBuffer as
+// UTF-8 text.
+(function (send, decode) {
+  function error(message, name) {
+    const err = new Error(message);
+    err.name = name;
+    return err;
+  }
+
+  class Headers {
+    constructor(init) {
+      this._list = [];
+      if (init instanceof Headers) {
+        init = init._list;
+      }
+      if (Array.isArray(init)) {
+        for (const [name, value] of init) {
+          this.append(name, value);
+        }
+      } else if (init) {
+        for (const name of Object.keys(init)) {
+          this.append(name, init[name]);
+        }
+      }
+    }
+
+    append(name, value) {
+      this._list.push([String(name).toLowerCase(), String(value)]);
+    }
+
+    set(name, value) {
+      this.delete(name);
+      this.append(name, value);
+    }
+
+    delete(name) {
+      name = String(name).toLowerCase();
+      this._list = this._list.filter(([n]) => n !== name);
+    }
+
+    get(name) {
+      name = String(name).toLowerCase();
+      const values = this._list.filter(([n]) => n === name).map(([, v]) => v);
+      return values.length > 0 ? values.join(", ") : null;
+    }
+
+    getSetCookie() {
+      return this._list.filter(([n]) => n === "set-cookie").map(([, v]) => v);
+    }
+
+    has(name) {
+      return this.get(name) !== null;
+    }
+
+    forEach(callback, thisArg) {
+      for (const [name, value] of this.entries()) {
+        callback.call(thisArg, value, name, this);
+      }
+    }
+
+    // Like in browsers, the entries are sorted by name and values of the
+    // same name are combined, except for Set-Cookie.
+    _entries() {
+      const names = [...new Set(this._list.map(([n]) => n))].sort();
+      const entries = [];
+      for (const name of names) {
+        if (name === "set-cookie") {
+          for (const value of this.getSetCookie()) {
+            entries.push([name, value]);
+          }
+        } else {
+          entries.push([name, this.get(name)]);
+        }
+      }
+      return entries;
+    }
+
+    entries() {
+      return this._entries()[Symbol.iterator]();
+    }
+
+    keys() {
+      return this._entries().map(([n]) => n)[Symbol.iterator]();
+    }
+
+    values() {
+      return this._entries().map(([, v]) => v)[Symbol.iterator]();
+    }
+
+    [Symbol.iterator]() {
+      return this.entries();
+    }
+  }
+
+  class AbortSignal {
+    constructor() {
+      this.aborted = false;
+      this.reason = undefined;
+      this.onabort = null;
+      this._listeners = [];
+    }
+
+    addEventListener(type, listener) {
+      if (type === "abort") {
+        this._listeners.push(listener);
+      }
+    }
+
+    removeEventListener(type, listener) {
+      this._listeners = this._listeners.filter((l) => l !== listener);
+    }
+
+    throwIfAborted() {
+      if (this.aborted) {
+        throw this.reason;
+      }
+    }
+
+    _abort(reason) {
+      if (this.aborted) {
+        return;
+      }
+      this.aborted = true;
+      this.reason = reason === undefined ? error("This operation was aborted", "AbortError") : reason;
+
+      const event = { type: "abort", target: this };
+      if (this.onabort) {
+        this.onabort(event);
+      }
+      for (const listener of this._listeners) {
+        listener(event);
+      }
+    }
+
+    static abort(reason) {
+      const signal = new AbortSignal();
+      signal._abort(reason);
+      return signal;
+    }
+
+    static timeout(ms) {
+      const signal = new AbortSignal();
+      setTimeout(() => signal._abort(error("The operation timed out.", "TimeoutError")), ms);
+      return signal;
+    }
+  }
+
+  class AbortController {
+    constructor() {
+      this.signal = new AbortSignal();
+    }
+
+    abort(reason) {
+      this.signal._abort(reason);
+    }
+  }
+
+  class Response {
+    constructor(body, init) {
+      this.status = init.status;
+      this.statusText = init.statusText;
+      this.ok = init.status >= 200 && init.status < 300;
+      this.headers = new Headers(init.headers);
+      this.url = init.url;
+      this.redirected = init.redirected;
+      this.bodyUsed = false;
+      this._body = body;
+    }
+
+    _consume() {
+      if (this.bodyUsed) {
+        return Promise.reject(new TypeError("Body has already been consumed."));
+      }
+      this.bodyUsed = true;
+      return Promise.resolve(this._body);
+    }
+
+    arrayBuffer() {
+      return this._consume();
+    }
+
+    bytes() {
+      return this._consume().then((body) => new Uint8Array(body));
+    }
+
+    text() {
+      return this._consume().then(decode);
+    }
+
+    json() {
+      return this.text().then(JSON.parse);
+    }
+  }
+
+  function fetch(input, init) {
+    init = init || {};
+
+    const signal = init.signal;
+    if (signal && signal.aborted) {
+      return Promise.reject(signal.reason);
+    }
+
+    let body = init.body;
+    if (body === undefined || body === null) {
+      body = undefined;
+    } else if (ArrayBuffer.isView(body)) {
+      body = body.buffer.slice(body.byteOffset, body.byteOffset + body.byteLength);
+    } else if (!(body instanceof ArrayBuffer)) {
+      body = String(body);
+    }
+
+    const method = String(init.method || "GET").toUpperCase();
+    const req = send(String(input), method, new Headers(init.headers)._list, body);
+
+    return new Promise((resolve, reject) => {
+      if (signal) {
+        signal.addEventListener("abort", () => {
+          req.abort();
+          reject(signal.reason);
+        });
+      }
+      req.promise.then(
+        (res) => resolve(new Response(res.body, res)),
+        (err) => reject(new TypeError("fetch failed: " + err.message)),
+      );
+    });
+  }
+
+  globalThis.fetch = fetch;
+  globalThis.Headers = Headers;
+  globalThis.AbortController = AbortController;
+  globalThis.AbortSignal = AbortSignal;
+})
@@ -5,12 +5,15 @@
 package flyscrape
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/inancgumus/screen"
@@ -19,42 +22,155 @@ import (
 
 var Version string
 
-func Run(file string, overrides map[string]any) error {
+type RunOptions struct {
+	// Resume records the progress of the run in a state file next to
+	// the script and continues from it, if a previous run was interrupted.
+	Resume bool
+
+	// Progress shows the progress of the run on stderr, if it is a terminal.
+	Progress bool
+
+	// MetricsAddr is the address that the metrics and the status of
+	// the run are served on, like ":9090".
+	MetricsAddr string
+
+	// Stats is the file that the summary of the run is written to as JSON.
+	Stats string
+}
+
+func Run(file string, overrides map[string]any, opts RunOptions) error {
 	src, err := os.ReadFile(file)
 	if err != nil {
 		return fmt.Errorf("failed to read script %q: %w", file, err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The state of an interrupted run is kept to be resumed.
+	var interrupted atomic.Bool
+	scraper := NewScraper()
+	trapsignal(func() {
+		interrupted.Store(true)
+		scraper.Stop()
+	}, cancel)
+
 	client := &http.Client{}
 
 	imports, wait := NewJSLibrary(client)
 	defer wait()
 
-	pop, err := pushDir(file)
+	exports, err := compile(string(src), filepath.Dir(file), imports)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to compile script: %w", err)
 	}
 
-	exports, err := Compile(string(src), imports)
+	cfg := exports.Config()
+	cfg = updateCfgMultiple(cfg, overrides)
+
+	scraper.ScrapeFunc = exports.Scrape
+	scraper.PriorityFunc = exports.Priority()
+	scraper.SetupFunc = exports.Setup()
+	scraper.TeardownFunc = exports.Teardown()
+	scraper.ErrorFunc = exports.OnError()
+	scraper.Script = file
+	scraper.Client = client
+	scraper.MetricsAddr = opts.MetricsAddr
+
+	scraper.Modules, scraper.Options, err = loadConfig(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to compile script: %w", err)
+		return err
+	}
+	scraper.Modules = withScriptModule(scraper.Modules, exports)
+	exports.SetRuntimes(scraper.Options.Runtimes)
+
+	if opts.Resume {
+		state, err := NewState(replaceExt(file, ".state"))
+		if err != nil {
+			return err
+		}
+		scraper.State = state
 	}
 
-	if err := pop(); err != nil {
+	var view *progressView
+	if opts.Progress {
+		view = newProgressView(os.Stderr, scraper)
+	}
+	if view != nil {
+		prev := logOutput.swap(view)
+		view.start()
+		defer logOutput.swap(prev)
+	}
+
+	err = scraper.Run(ctx)
+
+	if view != nil {
+		view.stop()
+	}
+
+	if err != nil {
+		if scraper.State != nil {
+			scraper.State.Close()
+		}
 		return err
 	}
 
+	summary := scraper.Stats().Summary()
+	fmt.Fprintln(os.Stderr, summary)
+
+	if opts.Stats != "" {
+		if err := writeSummary(opts.Stats, summary); err != nil {
+			return fmt.Errorf("failed to write stats file: %w", err)
+		}
+	}
+
+	if scraper.State == nil {
+		return nil
+	}
+
+	if interrupted.Load() {
+		if err := scraper.State.Close(); err != nil {
+			return fmt.Errorf("failed to save state file: %w", err)
+		}
+		return nil
+	}
+
+	if err := scraper.State.Remove(); err != nil {
+		return fmt.Errorf("failed to remove state file: %w", err)
+	}
+	return nil
+}
+
+// Explain loads the script and returns the verdicts of its modules about
+// a request to the URL at the given depth, without sending it.
+func Explain(file string, url string, depth int, overrides map[string]any) ([]Verdict, error) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script %q: %w", file, err)
+	}
+
+	client := &http.Client{}
+
+	imports, wait := NewJSLibrary(client)
+	defer wait()
+
+	exports, err := compile(string(src), filepath.Dir(file), imports)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile script: %w", err)
+	}
+
 	cfg := exports.Config()
 	cfg = updateCfgMultiple(cfg, overrides)
 
 	scraper := NewScraper()
-	scraper.ScrapeFunc = exports.Scrape
 	scraper.Script = file
 	scraper.Client = client
-	scraper.Modules = LoadModules(cfg)
+	scraper.Modules, scraper.Options, err = loadConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	scraper.Run()
-	return nil
+	return scraper.Explain(url, depth)
 }
 
 func Dev(file string, overrides map[string]any) error {
@@ -63,9 +179,22 @@ func Dev(file string, overrides map[string]any) error {
 		return fmt.Errorf("failed to create cache file: %w", err)
 	}
 
+	defer os.RemoveAll(cachefile)
+
+	// The first interrupt stops watching and lets the current run finish
+	// its in-flight requests, the second one aborts them.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	defer stopWatch()
+
+	var current atomic.Pointer[Scraper]
 	trapsignal(func() {
-		os.RemoveAll(cachefile)
-	})
+		stopWatch()
+		if scraper := current.Load(); scraper != nil {
+			scraper.Stop()
+		}
+	}, cancel)
 
 	fn := func(s string) error {
 		client := &http.Client{}
@@ -73,21 +202,12 @@ func Dev(file string, overrides map[string]any) error {
 		imports, wait := NewJSLibrary(client)
 		defer wait()
 
-		pop, err := pushDir(file)
-		if err != nil {
-			return err
-		}
-
-		exports, err := Compile(s, imports)
+		exports, err := compile(s, filepath.Dir(file), imports)
 		if err != nil {
 			printCompileErr(file, err)
 			return nil
 		}
 
-		if err := pop(); err != nil {
-			return err
-		}
-
 		cfg := exports.Config()
 		cfg = updateCfgMultiple(cfg, overrides)
 		cfg = updateCfg(cfg, "depth", 0)
@@ -95,18 +215,37 @@ func Dev(file string, overrides map[string]any) error {
 
 		scraper := NewScraper()
 		scraper.ScrapeFunc = exports.Scrape
+		scraper.PriorityFunc = exports.Priority()
+		scraper.SetupFunc = exports.Setup()
+		scraper.TeardownFunc = exports.Teardown()
+		scraper.ErrorFunc = exports.OnError()
 		scraper.Script = file
 		scraper.Client = client
-		scraper.Modules = LoadModules(cfg)
+		scraper.Modules, scraper.Options, err = loadConfig(cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return nil
+		}
+		scraper.Modules = withScriptModule(scraper.Modules, exports)
+		exports.SetRuntimes(scraper.Options.Runtimes)
+
+		current.Store(scraper)
+		defer current.Store(nil)
 
 		screen.Clear()
 		screen.MoveTopLeft()
-		scraper.Run()
+		if err := scraper.Run(ctx); err != nil {
+			slog.Error("failed to run", "error", err)
+			return nil
+		}
 
+		if watchCtx.Err() != nil {
+			return StopWatch
+		}
 		return nil
 	}
 
-	if err := Watch(file, fn); err != nil && err != StopWatch {
+	if err := Watch(watchCtx, file, fn); err != nil && err != StopWatch {
 		return fmt.Errorf("failed to watch script %q: %w", file, err)
 	}
 	return nil
@@ -118,10 +257,10 @@ func printCompileErr(script string, err error) {
 
 	if errs, ok := err.(interface{ Unwrap() []error }); ok {
 		for _, err := range errs.Unwrap() {
-			log.Printf("%s:%v\n", script, err)
+			fmt.Fprintf(os.Stderr, "%s:%v\n", script, err)
 		}
 	} else {
-		log.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 	}
 }
 
@@ -133,6 +272,22 @@ func updateCfg(cfg Config, key string, value any) Config {
 	return Config(newcfg)
 }
 
+func writeSummary(file string, summary Summary) error {
+	b, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(b, '\n'), 0644)
+}
+
+func replaceExt(filePath string, newExt string) string {
+	ext := filepath.Ext(filePath)
+	if ext != "" {
+		return filePath[:len(filePath)-len(ext)] + newExt
+	}
+	return filePath + newExt
+}
+
 func newCacheFile() (string, error) {
 	cachedir, err := os.MkdirTemp("", "flyscrape-cache")
 	if err != nil {
@@ -141,14 +296,23 @@ func newCacheFile() (string, error) {
 	return filepath.Join(cachedir, "dev.cache"), nil
 }
 
-func trapsignal(f func()) {
-	sig := make(chan os.Signal, 2)
+// trapsignal calls stop on the first interrupt, to let in-flight
+// requests finish, and cancel on the second one, to abort them. The
+// modules are finalized either way. The third interrupt exits the
+// process.
+func trapsignal(stop, cancel func()) {
+	sig := make(chan os.Signal, 3)
 	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		<-sig
-		f()
-		os.Exit(0)
+		slog.Info("shutting down, interrupt again to abort in-flight requests")
+		stop()
+		<-sig
+		slog.Info("aborting, interrupt again to force quit")
+		cancel()
+		<-sig
+		os.Exit(1)
 	}()
 }
 
@@ -165,21 +329,3 @@ func updateCfgMultiple(cfg Config, updates map[string]any) Config {
 
 	return []byte(c)
 }
-
-func pushDir(file string) (func() error, error) {
-	cwd, err := os.Getwd()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get current working directory: %w", err)
-	}
-	if err := os.Chdir(filepath.Dir(file)); err != nil {
-		return nil, fmt.Errorf("failed to change working directory: %w", err)
-	}
-	pop := func() error {
-		if err := os.Chdir(cwd); err != nil {
-			return fmt.Errorf("failed to change working directory: %w", err)
-		}
-		return nil
-	}
-	return pop, nil
-
-}
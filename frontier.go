@@ -0,0 +1,138 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape
+
+import (
+	"container/heap"
+	"sync"
+)
+
+const (
+	FrontierBFS      = "bfs"
+	FrontierDFS      = "dfs"
+	FrontierPriority = "priority"
+)
+
+// frontier holds the queued jobs and decides in which order they are
+// processed.
+type frontier interface {
+	// push adds a job to the frontier.
+	push(target) error
+
+	// pop removes the next job from the frontier. It blocks until a job
+	// is available and returns false once the frontier is closed.
+	pop() (target, bool)
+
+	// len returns the number of jobs in the frontier.
+	len() int
+
+	// close wakes up all waiting callers of pop.
+	close()
+}
+
+// PriorityFunc scores a URL. URLs with higher scores are processed first.
+type PriorityFunc func(url string, depth int) float64
+
+// priorityQueue is an in-memory frontier that processes the jobs with
+// the highest score first. Jobs with equal scores are processed in the
+// order they were added, or in reverse order if lifo is set.
+type priorityQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	closed bool
+
+	score func(target) float64
+	lifo  bool
+	items priorityItems
+	seq   uint64
+}
+
+func newPriorityQueue(score func(target) float64, lifo bool) *priorityQueue {
+	q := &priorityQueue{score: score, lifo: lifo}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *priorityQueue) push(t target) error {
+	// Scoring may call into the script, so don't hold the lock.
+	score := q.score(t)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	seq := q.seq
+	if q.lifo {
+		// Wraps around, so that later jobs sort first.
+		seq = -seq
+	}
+
+	heap.Push(&q.items, priorityItem{target: t, score: score, seq: seq})
+	q.cond.Signal()
+	return nil
+}
+
+func (q *priorityQueue) pop() (target, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.items) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return target{}, false
+	}
+
+	return heap.Pop(&q.items).(priorityItem).target, true
+}
+
+func (q *priorityQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+func (q *priorityQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+type priorityItem struct {
+	target target
+	score  float64
+	seq    uint64
+}
+
+type priorityItems []priorityItem
+
+func (h priorityItems) Len() int      { return len(h) }
+func (h priorityItems) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h priorityItems) Less(i, j int) bool {
+	if h[i].score != h[j].score {
+		return h[i].score > h[j].score
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h *priorityItems) Push(x any) {
+	*h = append(*h, x.(priorityItem))
+}
+
+func (h *priorityItems) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = priorityItem{}
+	*h = old[:n-1]
+	return item
+}
+
+var (
+	_ frontier = (*queue)(nil)
+	_ frontier = (*priorityQueue)(nil)
+)
@@ -18,7 +18,9 @@ require (
 	github.com/stretchr/testify v1.8.4
 	github.com/tidwall/sjson v1.2.5
 	go.etcd.io/bbolt v1.3.11
+	golang.org/x/net v0.31.0
 	golang.org/x/sync v0.9.0
+	golang.org/x/term v0.26.0
 )
 
 require (
@@ -49,9 +51,7 @@ require (
 	github.com/ysmood/leakless v0.8.0 // indirect
 	github.com/zalando/go-keyring v0.2.5 // indirect
 	golang.org/x/crypto v0.29.0 // indirect
-	golang.org/x/net v0.31.0 // indirect
 	golang.org/x/sys v0.27.0 // indirect
-	golang.org/x/term v0.26.0 // indirect
 	golang.org/x/text v0.20.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	www.velocidex.com/golang/go-ese v0.2.0 // indirect
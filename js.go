@@ -5,18 +5,22 @@
 package flyscrape
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"net/url"
+	"reflect"
+	"runtime"
 	"strings"
 	"sync"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/dop251/goja"
-	"github.com/dop251/goja_nodejs/console"
+	"github.com/dop251/goja_nodejs/eventloop"
 	"github.com/dop251/goja_nodejs/require"
 	"github.com/evanw/esbuild/pkg/api"
 )
@@ -27,14 +31,44 @@ var ScriptTemplate []byte
 type Config []byte
 
 type ScrapeParams struct {
-	HTML    string
-	URL     string
-	Process func(url string) ([]byte, error)
-	Follow  func(url string)
+	HTML     string
+	URL      string
+	Meta     any
+	Referrer string
+	Setup    any
+	Process  func(ctx context.Context, url string) ([]byte, error)
+	Fetch    FetchFunc
+	Follow   func(FollowRequest)
 }
 
 type ScrapeFunc func(ScrapeParams) (any, error)
 
+// FetchFunc sends the requests of fetch.
+type FetchFunc func(*http.Request) (*http.Response, error)
+
+// SetupFunc runs the setup function of the script. Its result is passed
+// to all other functions of the script.
+type SetupFunc func(fetch FetchFunc) (any, error)
+
+// TeardownFunc runs the teardown function of the script with the stats
+// of the run.
+type TeardownFunc func(summary Summary, setup any, fetch FetchFunc) error
+
+type ErrorParams struct {
+	URL     string
+	Status  int
+	Error   string
+	Attempt int
+	Meta    any
+	Setup   any
+	Retry   func()
+	Fetch   FetchFunc
+}
+
+// ErrorFunc runs the onError function of the script for a failed
+// response. Its result, if any, is used as the data of the response.
+type ErrorFunc func(ErrorParams) (any, error)
+
 type TransformError struct {
 	Line   int
 	Column int
@@ -52,22 +86,63 @@ func (e Exports) Config() []byte {
 	return b
 }
 
+// SetRuntimes sets the number of runtimes that run the script in
+// parallel. Zero means one per CPU.
+func (e Exports) SetRuntimes(n int) {
+	if pool, ok := e["__pool"].(*runtimePool); ok {
+		pool.resize(n)
+	}
+}
+
 func (e Exports) Scrape(p ScrapeParams) (any, error) {
 	fn := e["__scrape"].(ScrapeFunc)
 	return fn(p)
 }
 
+// Priority returns the priority function exported by the script,
+// or nil if there is none.
+func (e Exports) Priority() PriorityFunc {
+	fn, _ := e["__priority"].(PriorityFunc)
+	return fn
+}
+
+// Setup returns the setup function exported by the script, or nil if
+// there is none.
+func (e Exports) Setup() SetupFunc {
+	fn, _ := e["__setup"].(SetupFunc)
+	return fn
+}
+
+// Teardown returns the teardown function exported by the script, or nil
+// if there is none.
+func (e Exports) Teardown() TeardownFunc {
+	fn, _ := e["__teardown"].(TeardownFunc)
+	return fn
+}
+
+// OnError returns the onError function exported by the script, or nil
+// if there is none.
+func (e Exports) OnError() ErrorFunc {
+	fn, _ := e["__onError"].(ErrorFunc)
+	return fn
+}
+
 type Imports map[string]map[string]any
 
 func Compile(src string, imports Imports) (Exports, error) {
-	src, err := build(src)
+	return compile(src, ".", imports)
+}
+
+// compile compiles the script, resolving its imports from dir.
+func compile(src string, dir string, imports Imports) (Exports, error) {
+	src, err := build(src, dir)
 	if err != nil {
 		return nil, err
 	}
 	return vm(src, imports)
 }
 
-func build(src string) (string, error) {
+func build(src string, dir string) (string, error) {
 	res := api.Build(api.BuildOptions{
 		Loader: map[string]api.Loader{
 			".txt":  api.LoaderText,
@@ -76,7 +151,7 @@ func build(src string) (string, error) {
 		Bundle: true,
 		Stdin: &api.StdinOptions{
 			Contents:   src,
-			ResolveDir: ".",
+			ResolveDir: dir,
 		},
 		Platform: api.PlatformNode,
 		Format:   api.FormatCommonJS,
@@ -103,72 +178,561 @@ func build(src string) (string, error) {
 }
 
 func vm(src string, imports Imports) (Exports, error) {
-	vm := goja.New()
+	prog, err := goja.Compile("", src, false)
+	if err != nil {
+		return nil, fmt.Errorf("running user script: %w", err)
+	}
+
+	pool := &runtimePool{
+		prog:    prog,
+		imports: imports,
+		max:     runtime.GOMAXPROCS(0),
+	}
+	pool.cond = sync.NewCond(&pool.mu)
+
+	rt, err := pool.newRuntime()
+	if err != nil {
+		return nil, err
+	}
+
+	exports := Exports{}
+	var defined bool
+	rt.run(func(vm *goja.Runtime) {
+		var v goja.Value
+		if v, err = vm.RunString("module.exports"); err != nil || goja.IsUndefined(v) {
+			return
+		}
+
+		defined = true
+		obj := v.ToObject(vm)
+		for _, key := range obj.Keys() {
+			exports[key] = obj.Get(key).Export()
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+	if !defined {
+		return exports, nil
+	}
+
+	if rt.scrape == nil {
+		return nil, errors.New("default export is not defined")
+	}
+
+	pool.size = 1
+	pool.idle = append(pool.idle, rt)
+
+	exports["__pool"] = pool
+	exports["__scrape"] = ScrapeFunc(pool.scrape)
+	if rt.priority != nil {
+		exports["__priority"] = PriorityFunc(pool.priority)
+	}
+	if rt.funcs["setup"] != nil {
+		exports["__setup"] = SetupFunc(pool.setup)
+	}
+	if rt.funcs["teardown"] != nil {
+		exports["__teardown"] = TeardownFunc(pool.teardown)
+	}
+	if rt.funcs["onError"] != nil {
+		exports["__onError"] = ErrorFunc(pool.onError)
+	}
+	if rt.funcs["beforeRequest"] != nil {
+		exports["__beforeRequest"] = BeforeRequestFunc(pool.beforeRequest)
+	}
+	if rt.funcs["afterResponse"] != nil {
+		exports["__afterResponse"] = AfterResponseFunc(pool.afterResponse)
+	}
+
+	return exports, nil
+}
+
+// Async marks an imported function to return a Promise of its result.
+// Each call runs in its own goroutine, so that calls like
+// Promise.all([get(a), get(b)]) run concurrently.
+func Async(fn any) any {
+	return asyncFunc{fn: reflect.ValueOf(fn)}
+}
+
+type asyncFunc struct {
+	fn reflect.Value
+}
+
+// jsRuntime is an instance of the script with its own module state.
+// It runs on an event loop, which is only started while it is in use.
+type jsRuntime struct {
+	pool     *runtimePool
+	loop     *eventloop.EventLoop
+	scrape   ScrapeFunc
+	priority PriorityFunc
+
+	// funcs are the exported functions that are called with call.
+	funcs map[string]goja.Value
+
+	// invoke calls a function and settles with its JSON encoded result.
+	invoke goja.Callable
+
+	// fetcher sends the requests of fetch during a call.
+	fetcher FetchFunc
+
+	// done ends the current call of do.
+	done func(panicked any)
+
+	mu      sync.Mutex
+	settled *sync.Cond
+	pending int
+}
+
+// do starts the loop and runs fn on it. It returns once fn has called
+// done and all Promises of async calls are settled. A panic on the loop
+// is passed on to the caller.
+func (rt *jsRuntime) do(fn func(vm *goja.Runtime, done func())) {
+	finished := make(chan any, 1)
+	var once sync.Once
+	rt.done = func(panicked any) {
+		once.Do(func() { finished <- panicked })
+	}
+
+	rt.loop.Start()
+	rt.onLoop(func(vm *goja.Runtime) {
+		fn(vm, func() { rt.done(nil) })
+	})
+	panicked := <-finished
+
+	rt.mu.Lock()
+	for rt.pending > 0 {
+		rt.settled.Wait()
+	}
+	rt.mu.Unlock()
+	rt.loop.Stop()
+
+	if panicked != nil {
+		panic(panicked)
+	}
+}
+
+// run is like do for functions that are done once they return.
+func (rt *jsRuntime) run(fn func(vm *goja.Runtime)) {
+	rt.do(func(vm *goja.Runtime, done func()) {
+		defer done()
+		fn(vm)
+	})
+}
+
+func (rt *jsRuntime) onLoop(fn func(vm *goja.Runtime)) {
+	rt.loop.RunOnLoop(func(vm *goja.Runtime) {
+		defer func() {
+			if r := recover(); r != nil {
+				rt.done(r)
+			}
+		}()
+		fn(vm)
+	})
+}
+
+// async calls fn in its own goroutine and returns a Promise that is
+// settled with its result. If then is set, it is called on the loop
+// with the result and settles the Promise instead.
+func (rt *jsRuntime) async(vm *goja.Runtime, fn func() (any, error), then func(any, error) (any, error)) goja.Value {
+	promise, resolve, reject := vm.NewPromise()
+	rt.add(1)
+
+	go func() {
+		v, err := func() (v any, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("%v", r)
+				}
+			}()
+			return fn()
+		}()
+
+		rt.onLoop(func(vm *goja.Runtime) {
+			defer rt.add(-1)
+
+			if then != nil {
+				v, err = then(v, err)
+			}
+			if err != nil {
+				reject(vm.NewGoError(err))
+				return
+			}
+			resolve(v)
+		})
+	}()
+
+	return vm.ToValue(promise)
+}
+
+// call calls fn with the arguments returned by args and waits for the
+// Promise it may return. Like the results of the default export, the
+// result is passed through JSON.
+func (rt *jsRuntime) call(fetch FetchFunc, fn goja.Value, args func(vm *goja.Runtime) ([]goja.Value, error)) (any, error) {
+	rt.fetcher = fetch
+	defer func() { rt.fetcher = nil }()
+
+	var res callResult
+	var unsettled bool
+	rt.do(func(vm *goja.Runtime, done func()) {
+		rt.invokeOnLoop(vm, fn, args, func(r callResult) {
+			rt.mu.Lock()
+			unsettled = rt.pending > 0
+			rt.mu.Unlock()
+
+			res = r
+			done()
+		})
+	})
+
+	// Requests that are still running were not awaited, like the result
+	// of http.get or scrape in a function that is not async.
+	if unsettled {
+		slog.Warn("script returned before its requests finished, a Promise was not awaited")
+	}
+	return res.decode()
+}
+
+// callInUse is like call for a runtime that is already in use by the
+// calling goroutine, like a request of the script that is sent from an
+// async call. The loop is kept running by that call.
+func (rt *jsRuntime) callInUse(fn goja.Value, args func(vm *goja.Runtime) ([]goja.Value, error)) (any, error) {
+	ch := make(chan callResult, 1)
+	rt.onLoop(func(vm *goja.Runtime) {
+		rt.invokeOnLoop(vm, fn, args, func(r callResult) {
+			ch <- r
+		})
+	})
+	res := <-ch
+	return res.decode()
+}
+
+// invokeOnLoop calls fn and passes its JSON encoded result to settle,
+// once the Promise it may return is settled.
+func (rt *jsRuntime) invokeOnLoop(vm *goja.Runtime, fn goja.Value, args func(vm *goja.Runtime) ([]goja.Value, error), settle func(callResult)) {
+	var a []goja.Value
+	if args != nil {
+		var err error
+		if a, err = args(vm); err != nil {
+			settle(callResult{err: err})
+			return
+		}
+	}
+
+	resolve := func(v goja.Value) {
+		if v != nil && !goja.IsUndefined(v) {
+			settle(callResult{ret: v.String(), defined: true})
+			return
+		}
+		settle(callResult{})
+	}
+	reject := func(reason goja.Value) {
+		settle(callResult{err: errors.New(reason.String())})
+	}
+
+	items := make([]any, len(a))
+	for i, v := range a {
+		items[i] = v
+	}
+
+	if _, err := rt.invoke(goja.Undefined(), fn, vm.NewArray(items...), vm.ToValue(resolve), vm.ToValue(reject)); err != nil {
+		settle(callResult{err: err})
+	}
+}
+
+type callResult struct {
+	ret     string
+	defined bool
+	err     error
+}
+
+func (r callResult) decode() (any, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if !r.defined {
+		return nil, nil
+	}
+
+	var result any
+	if err := json.Unmarshal([]byte(r.ret), &result); err != nil {
+		slog.Error("failed to decode result", "error", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+type runtimeKey struct{}
+
+// withRuntime marks the requests of the script running on rt, so that
+// its request hooks run on the runtime that is already in use by the
+// script instead of waiting for another one.
+func withRuntime(ctx context.Context, rt *jsRuntime) context.Context {
+	return context.WithValue(ctx, runtimeKey{}, rt)
+}
+
+func runtimeFrom(ctx context.Context) *jsRuntime {
+	if ctx == nil {
+		return nil
+	}
+	rt, _ := ctx.Value(runtimeKey{}).(*jsRuntime)
+	return rt
+}
+
+func (rt *jsRuntime) add(n int) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.pending += n
+	if rt.pending == 0 {
+		rt.settled.Broadcast()
+	}
+}
+
+// export converts an imported value for the runtime.
+func (rt *jsRuntime) export(vm *goja.Runtime, v any) any {
+	async, ok := v.(asyncFunc)
+	if !ok {
+		return v
+	}
+
+	return func(call goja.FunctionCall) goja.Value {
+		t := async.fn.Type()
+		args := make([]reflect.Value, t.NumIn())
+		for i := range args {
+			arg := reflect.New(t.In(i))
+			if a := call.Argument(i); !goja.IsUndefined(a) {
+				if err := vm.ExportTo(a, arg.Interface()); err != nil {
+					panic(vm.NewTypeError(err.Error()))
+				}
+			}
+			args[i] = arg.Elem()
+		}
+
+		return rt.async(vm, func() (any, error) {
+			out := async.fn.Call(args)
+			if len(out) == 0 {
+				return nil, nil
+			}
+			return out[0].Interface(), nil
+		}, nil)
+	}
+}
+
+// runtimePool runs the script in up to max runtimes, so that pages are
+// scraped in parallel. Runtimes are created on demand and are used by
+// one call at a time. As each of them runs the script on its own, the
+// module-level code runs once per runtime and its variables are not
+// shared between them.
+type runtimePool struct {
+	prog    *goja.Program
+	imports Imports
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	idle []*jsRuntime
+	size int
+	max  int
+}
+
+func (p *runtimePool) newRuntime() (*jsRuntime, error) {
 	registry := &require.Registry{}
 
-	registry.Enable(vm)
-	console.Enable(vm)
+	rt := &jsRuntime{pool: p, loop: eventloop.NewEventLoop(eventloop.WithRegistry(registry))}
+	rt.settled = sync.NewCond(&rt.mu)
 
-	for module, pkg := range imports {
+	for module, pkg := range p.imports {
 		pkg := pkg
 		registry.RegisterNativeModule(module, func(vm *goja.Runtime, o *goja.Object) {
 			exports := vm.NewObject()
 
 			for ident, val := range pkg {
-				exports.Set(ident, val)
+				exports.Set(ident, rt.export(vm, val))
 			}
 
 			o.Set("exports", exports)
 		})
 	}
 
-	if _, err := vm.RunString("module = {}"); err != nil {
-		return nil, fmt.Errorf("running defining module: %w", err)
-	}
-	if _, err := vm.RunString(src); err != nil {
-		return nil, fmt.Errorf("running user script: %w", err)
-	}
+	var err error
+	rt.run(func(vm *goja.Runtime) {
+		if err = enableFetch(rt, vm); err != nil {
+			return
+		}
+		if _, err = vm.RunString("module = {}"); err != nil {
+			err = fmt.Errorf("running defining module: %w", err)
+			return
+		}
+		if _, err = vm.RunProgram(p.prog); err != nil {
+			err = fmt.Errorf("running user script: %w", err)
+			return
+		}
 
-	v, err := vm.RunString("module.exports")
+		// The function may return a Promise. Promises in the result were
+		// not awaited and would be encoded as {}, so they fail the call.
+		var v goja.Value
+		if v, err = vm.RunString(`(fn, args, resolve, reject) => {
+			const replacer = (key, v) => {
+				if (v instanceof Promise) {
+					throw new TypeError("result contains a Promise" + (key ? " at " + JSON.stringify(key) : "") + ", it has to be awaited");
+				}
+				return v;
+			};
+			new Promise((r) => r(fn(...args)))
+				.then((v) => JSON.stringify(v, replacer))
+				.then(resolve, reject);
+		}`); err != nil {
+			err = fmt.Errorf("failed to create call function: %w", err)
+			return
+		}
+		rt.invoke, _ = goja.AssertFunction(v)
+
+		rt.funcs = map[string]goja.Value{}
+		for _, name := range []string{"default", "setup", "teardown", "onError", "beforeRequest", "afterResponse"} {
+			if v, _ := vm.RunString("module.exports." + name); v != nil {
+				if _, ok := goja.AssertFunction(v); ok {
+					rt.funcs[name] = v
+				}
+			}
+		}
+		if err = wrapBeforeRequest(rt, vm); err != nil {
+			return
+		}
+
+		rt.priority = priority(rt, vm)
+		if rt.funcs["default"] != nil {
+			rt.scrape = scrape(rt, vm)
+		}
+	})
 	if err != nil {
-		return nil, fmt.Errorf("reading config: %w", err)
+		return nil, err
 	}
 
-	exports := Exports{}
-	if goja.IsUndefined(v) {
-		return exports, nil
+	return rt, nil
+}
+
+// get returns an idle runtime, creates a new one if the pool is not
+// full yet, or waits for one to become idle.
+func (p *runtimePool) get() (*jsRuntime, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		if n := len(p.idle); n > 0 {
+			rt := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			return rt, nil
+		}
+
+		if p.size < p.max {
+			p.size++
+			p.mu.Unlock()
+			rt, err := p.newRuntime()
+			p.mu.Lock()
+			if err != nil {
+				p.size--
+				p.cond.Signal()
+				return nil, err
+			}
+			return rt, nil
+		}
+
+		p.cond.Wait()
 	}
+}
+
+func (p *runtimePool) put(rt *jsRuntime) {
+	p.mu.Lock()
+	p.idle = append(p.idle, rt)
+	p.mu.Unlock()
+	p.cond.Signal()
+}
 
-	obj := v.ToObject(vm)
-	for _, key := range obj.Keys() {
-		exports[key] = obj.Get(key).Export()
+// resize sets the maximum number of runtimes. Zero means one per CPU.
+func (p *runtimePool) resize(n int) {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
 	}
 
-	exports["__scrape"], err = scrape(vm)
+	p.mu.Lock()
+	p.max = n
+	p.mu.Unlock()
+	p.cond.Broadcast()
+}
+
+func (p *runtimePool) scrape(params ScrapeParams) (any, error) {
+	rt, err := p.get()
 	if err != nil {
 		return nil, err
 	}
+	defer p.put(rt)
 
-	return exports, nil
+	return rt.scrape(params)
 }
 
-func scrape(vm *goja.Runtime) (ScrapeFunc, error) {
-	var lock sync.Mutex
-
-	if v, err := vm.RunString("module.exports.default"); err != nil || goja.IsUndefined(v) {
-		return nil, errors.New("default export is not defined")
+// call calls an exported function on an idle runtime.
+func (p *runtimePool) call(name string, fetch FetchFunc, args func(vm *goja.Runtime) ([]goja.Value, error)) (any, error) {
+	rt, err := p.get()
+	if err != nil {
+		return nil, err
 	}
+	defer p.put(rt)
 
-	defaultfn, err := vm.RunString("(o) => JSON.stringify(module.exports.default(o))")
+	return rt.call(fetch, rt.funcs[name], args)
+}
+
+func (p *runtimePool) setup(fetch FetchFunc) (any, error) {
+	return p.call("setup", fetch, nil)
+}
+
+func (p *runtimePool) teardown(summary Summary, setup any, fetch FetchFunc) error {
+	// Round trip through JSON, so that the summary has the same keys as
+	// in the output.
+	b, err := json.Marshal(summary)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create scrape function: %w", err)
+		return err
+	}
+	var s any
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
 	}
 
-	scrapefn, ok := defaultfn.Export().(func(goja.FunctionCall) goja.Value)
-	if !ok {
-		return nil, errors.New("failed to export scrape function")
+	_, err = p.call("teardown", fetch, func(vm *goja.Runtime) ([]goja.Value, error) {
+		return []goja.Value{vm.ToValue(s), vm.ToValue(setup)}, nil
+	})
+	return err
+}
+
+func (p *runtimePool) onError(params ErrorParams) (any, error) {
+	return p.call("onError", params.Fetch, func(vm *goja.Runtime) ([]goja.Value, error) {
+		o := vm.NewObject()
+		o.Set("url", params.URL)
+		o.Set("status", params.Status)
+		o.Set("error", params.Error)
+		o.Set("attempt", params.Attempt)
+		o.Set("meta", params.Meta)
+		o.Set("setup", params.Setup)
+		o.Set("retry", func() {
+			if params.Retry != nil {
+				params.Retry()
+			}
+		})
+		return []goja.Value{o}, nil
+	})
+}
+
+func (p *runtimePool) priority(url string, depth int) float64 {
+	rt, err := p.get()
+	if err != nil {
+		slog.Error("priority function failed", "url", url, "error", err)
+		return 0
 	}
+	defer p.put(rt)
 
+	return rt.priority(url, depth)
+}
+
+func scrape(rt *jsRuntime, vm *goja.Runtime) ScrapeFunc {
 	var newArg func(p ScrapeParams) (*goja.Object, error)
 	newArg = func(p ScrapeParams) (*goja.Object, error) {
 		doc, err := DocumentFromString(p.HTML)
@@ -191,58 +755,155 @@ func scrape(vm *goja.Runtime) (ScrapeFunc, error) {
 
 		o := vm.NewObject()
 		o.Set("url", p.URL)
+		o.Set("meta", p.Meta)
+		o.Set("referrer", p.Referrer)
+		o.Set("setup", p.Setup)
 		o.Set("doc", doc)
 		o.Set("absoluteURL", absoluteURL)
-		o.Set("scrape", func(url string, f func(goja.FunctionCall) goja.Value) goja.Value {
+		o.Set("scrape", func(url string, f goja.Value) goja.Value {
 			url = absoluteURL(url)
 
-			html, err := p.Process(url)
-			if err != nil {
-				return vm.ToValue(map[string]any{"error": err.Error()})
-			}
-
-			newp := ScrapeParams{
-				HTML:    string(html),
-				URL:     url,
-				Process: p.Process,
+			fn, ok := goja.AssertFunction(f)
+			if !ok {
+				panic(vm.NewTypeError("scrape: callback is not a function"))
 			}
 
-			arg, err := newArg(newp)
-			if err != nil {
-				return vm.ToValue(map[string]any{"error": err.Error()})
+			fetch := func() (any, error) {
+				return p.Process(withRuntime(context.Background(), rt), url)
 			}
 
-			return f(goja.FunctionCall{Arguments: []goja.Value{arg}})
+			return rt.async(vm, fetch, func(html any, err error) (any, error) {
+				if err != nil {
+					return map[string]any{"error": err.Error()}, nil
+				}
+
+				newp := ScrapeParams{
+					HTML:    string(html.([]byte)),
+					URL:     url,
+					Setup:   p.Setup,
+					Process: p.Process,
+				}
+
+				arg, err := newArg(newp)
+				if err != nil {
+					return map[string]any{"error": err.Error()}, nil
+				}
+
+				return fn(goja.Undefined(), arg)
+			})
 		})
-		o.Set("follow", func(url string) {
-			p.Follow(absoluteURL(url))
+		o.Set("follow", func(arg, opts goja.Value) {
+			f := followRequest(vm, arg, opts)
+			f.URL = absoluteURL(f.URL)
+			p.Follow(f)
 		})
 
 		return o, nil
 	}
 
 	return func(p ScrapeParams) (any, error) {
-		lock.Lock()
-		defer lock.Unlock()
+		return rt.call(p.Fetch, rt.funcs["default"], func(vm *goja.Runtime) ([]goja.Value, error) {
+			arg, err := newArg(p)
+			if err != nil {
+				return nil, err
+			}
+			return []goja.Value{arg}, nil
+		})
+	}
+}
 
-		arg, err := newArg(p)
-		if err != nil {
-			return nil, err
+// followRequest converts the arguments of follow. The first one is either
+// a URL or an object like {url, method, body, headers, data}. Bodies that
+// are not strings are sent as JSON. The optional second one is an object
+// like {data}.
+func followRequest(vm *goja.Runtime, arg, opts goja.Value) FollowRequest {
+	if s, ok := arg.Export().(string); ok {
+		f := FollowRequest{URL: s}
+		if opts != nil && !goja.IsUndefined(opts) && !goja.IsNull(opts) {
+			f.Data = followData(vm, opts.ToObject(vm))
 		}
+		return f
+	}
 
-		ret := scrapefn(goja.FunctionCall{Arguments: []goja.Value{arg}})
-		if goja.IsUndefined(ret) {
-			return nil, nil
-		}
+	o := arg.ToObject(vm)
 
-		var result any
-		if err := json.Unmarshal([]byte(ret.String()), &result); err != nil {
-			log.Println(err)
-			return nil, err
+	f := FollowRequest{Headers: http.Header{}}
+	if v := o.Get("url"); v != nil && !goja.IsUndefined(v) {
+		f.URL = v.String()
+	}
+	if f.URL == "" {
+		panic(vm.NewTypeError("follow: url is required"))
+	}
+	if v := o.Get("method"); v != nil && !goja.IsUndefined(v) {
+		f.Method = v.String()
+	}
+	if v := o.Get("headers"); v != nil && !goja.IsUndefined(v) {
+		headers := v.ToObject(vm)
+		for _, k := range headers.Keys() {
+			f.Headers.Set(k, headers.Get(k).String())
 		}
+	}
+	if v := o.Get("body"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+		if s, ok := v.Export().(string); ok {
+			f.Body = []byte(s)
+		} else {
+			b, err := json.Marshal(v.Export())
+			if err != nil {
+				panic(vm.NewTypeError("follow: invalid body: %v", err))
+			}
+			f.Body = b
+			if f.Headers.Get("Content-Type") == "" {
+				f.Headers.Set("Content-Type", "application/json")
+			}
+		}
+	}
+	f.Data = followData(vm, o)
+
+	return f
+}
 
-		return result, nil
-	}, nil
+func followData(vm *goja.Runtime, o *goja.Object) any {
+	v := o.Get("data")
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil
+	}
+
+	// Round trip through JSON, so that the data is carried the same way
+	// whether the job stays in memory or is written to disk.
+	b, err := json.Marshal(v.Export())
+	if err != nil {
+		panic(vm.NewTypeError("follow: invalid data: %v", err))
+	}
+	var data any
+	if err := json.Unmarshal(b, &data); err != nil {
+		panic(vm.NewTypeError("follow: invalid data: %v", err))
+	}
+	return data
+}
+
+func priority(rt *jsRuntime, vm *goja.Runtime) PriorityFunc {
+	v, err := vm.RunString("module.exports.priority")
+	if err != nil {
+		return nil
+	}
+
+	fn, ok := goja.AssertFunction(v)
+	if !ok {
+		return nil
+	}
+
+	return func(url string, depth int) float64 {
+		var score float64
+		rt.run(func(vm *goja.Runtime) {
+			v, err := fn(goja.Undefined(), vm.ToValue(url), vm.ToValue(depth))
+			if err != nil {
+				slog.Error("priority function failed", "url", url, "error", err)
+				return
+			}
+			score = v.ToFloat()
+		})
+		return score
+	}
 }
 
 func DocumentFromString(s string) (map[string]any, error) {
@@ -0,0 +1,135 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/dop251/goja"
+)
+
+//go:embed fetch.js
+var fetchJS string
+
+// enableFetch defines fetch on the runtime. Requests are sent with the
+// fetch function of the current scrape call, so that they go through
+// the modules like the requests of crawled pages.
+func enableFetch(rt *jsRuntime, vm *goja.Runtime) error {
+	v, err := vm.RunScript("fetch.js", fetchJS)
+	if err != nil {
+		return fmt.Errorf("failed to define fetch: %w", err)
+	}
+
+	define, ok := goja.AssertFunction(v)
+	if !ok {
+		return errors.New("failed to define fetch")
+	}
+
+	decode := func(v goja.Value) string {
+		b, _ := v.Export().(goja.ArrayBuffer)
+		return string(b.Bytes())
+	}
+
+	_, err = define(goja.Undefined(), vm.ToValue(jsSend(rt, vm)), vm.ToValue(decode))
+	return err
+}
+
+type fetchResult struct {
+	resp *http.Response
+	body []byte
+}
+
+// jsSend returns the native function behind fetch. It returns an object
+// like {promise, abort}, as the signal is handled in JavaScript.
+func jsSend(rt *jsRuntime, vm *goja.Runtime) func(string, string, [][]string, goja.Value) *goja.Object {
+	return func(url, method string, headers [][]string, body goja.Value) *goja.Object {
+		fetch := rt.fetcher
+		ctx, cancel := context.WithCancel(withRuntime(context.Background(), rt))
+
+		var r io.Reader
+		switch b := body.Export().(type) {
+		case string:
+			r = bytes.NewReader([]byte(b))
+		case goja.ArrayBuffer:
+			r = bytes.NewReader(bytes.Clone(b.Bytes()))
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, url, r)
+		if err == nil {
+			for _, h := range headers {
+				req.Header.Add(h[0], h[1])
+			}
+		}
+
+		send := func() (any, error) {
+			if err != nil {
+				return nil, err
+			}
+			if fetch == nil {
+				return nil, errors.New("fetch is only available while scraping")
+			}
+
+			resp, err := fetch(req)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+
+			b, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return nil, err
+			}
+			return fetchResult{resp: resp, body: b}, nil
+		}
+
+		response := func(v any, err error) (any, error) {
+			cancel()
+			if err != nil {
+				return nil, err
+			}
+
+			res := v.(fetchResult)
+
+			names := make([]string, 0, len(res.resp.Header))
+			for name := range res.resp.Header {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			headers := [][]string{}
+			for _, name := range names {
+				for _, value := range res.resp.Header[name] {
+					headers = append(headers, []string{name, value})
+				}
+			}
+
+			finalURL := req.URL.String()
+			if res.resp.Request != nil {
+				finalURL = res.resp.Request.URL.String()
+			}
+
+			return map[string]any{
+				"status":     res.resp.StatusCode,
+				"statusText": http.StatusText(res.resp.StatusCode),
+				"headers":    headers,
+				"url":        finalURL,
+				"redirected": finalURL != req.URL.String(),
+				"body":       vm.NewArrayBuffer(res.body),
+			}, nil
+		}
+
+		o := vm.NewObject()
+		o.Set("promise", rt.async(vm, send, response))
+		o.Set("abort", cancel)
+		return o
+	}
+}
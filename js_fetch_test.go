@@ -0,0 +1,132 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/philippta/flyscrape"
+	"github.com/stretchr/testify/require"
+)
+
+func fetchScrape(t *testing.T, js string, fetch func(*http.Request) (*http.Response, error)) any {
+	exports, err := flyscrape.Compile(js, nil)
+	require.NoError(t, err)
+
+	result, err := exports.Scrape(flyscrape.ScrapeParams{
+		HTML:  html,
+		URL:   "http://localhost/",
+		Fetch: fetch,
+	})
+	require.NoError(t, err)
+	return result
+}
+
+func TestJSFetch(t *testing.T) {
+	js := `
+    export default async function() {
+        const res = await fetch("http://localhost/api", {
+            method: "post",
+            headers: { "X-Foo": "bar" },
+            body: JSON.stringify({ a: 1 }),
+        });
+        return {
+            status: res.status,
+            ok: res.ok,
+            url: res.url,
+            type: res.headers.get("Content-Type"),
+            cookies: res.headers.getSetCookie(),
+            data: await res.json(),
+        };
+    }
+    `
+
+	result := fetchScrape(t, js, func(r *http.Request) (*http.Response, error) {
+		require.Equal(t, "POST", r.Method)
+		require.Equal(t, "http://localhost/api", r.URL.String())
+		require.Equal(t, "bar", r.Header.Get("X-Foo"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, `{"a":1}`, string(body))
+
+		resp, err := flyscrape.MockResponse(201, `{"b": 2}`)
+		resp.Header.Set("Content-Type", "application/json")
+		resp.Header.Add("Set-Cookie", "a=1")
+		resp.Header.Add("Set-Cookie", "b=2")
+		return resp, err
+	})
+
+	require.Equal(t, map[string]any{
+		"status":  float64(201),
+		"ok":      true,
+		"url":     "http://localhost/api",
+		"type":    "application/json",
+		"cookies": []any{"a=1", "b=2"},
+		"data":    map[string]any{"b": float64(2)},
+	}, result)
+}
+
+func TestJSFetchArrayBuffer(t *testing.T) {
+	js := `
+    export default async function() {
+        const res = await fetch("http://localhost/image.png");
+        return Array.from(new Uint8Array(await res.arrayBuffer()));
+    }
+    `
+
+	result := fetchScrape(t, js, func(r *http.Request) (*http.Response, error) {
+		return flyscrape.MockResponse(200, "\x00\x01\xff")
+	})
+
+	require.Equal(t, []any{float64(0), float64(1), float64(255)}, result)
+}
+
+func TestJSFetchAbort(t *testing.T) {
+	js := `
+    export default async function() {
+        const controller = new AbortController();
+        const res = fetch("http://localhost/slow", { signal: controller.signal });
+        controller.abort();
+
+        const errors = [];
+        try {
+            await res;
+        } catch (err) {
+            errors.push(err.name);
+        }
+        try {
+            await fetch("http://localhost/slow", { signal: AbortSignal.timeout(10) });
+        } catch (err) {
+            errors.push(err.name);
+        }
+        return errors;
+    }
+    `
+
+	result := fetchScrape(t, js, func(r *http.Request) (*http.Response, error) {
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	})
+
+	require.Equal(t, []any{"AbortError", "TimeoutError"}, result)
+}
+
+func TestJSFetchError(t *testing.T) {
+	js := `
+    export default async function() {
+        try {
+            await fetch("http://localhost/");
+        } catch (err) {
+            return err.name + ": " + err.message;
+        }
+    }
+    `
+
+	result := fetchScrape(t, js, nil)
+	require.Equal(t, "TypeError: fetch failed: fetch is only available while scraping", result)
+}
@@ -0,0 +1,217 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// BeforeRequestFunc runs the beforeRequest function of the script. It
+// may modify the request and reports whether the request is sent.
+type BeforeRequestFunc func(*Request) (bool, error)
+
+// AfterResponseFunc runs the afterResponse function of the script and
+// reports whether the response is scraped.
+type AfterResponseFunc func(*Response) (bool, error)
+
+// BeforeRequest returns the beforeRequest function exported by the
+// script, or nil if there is none.
+func (e Exports) BeforeRequest() BeforeRequestFunc {
+	fn, _ := e["__beforeRequest"].(BeforeRequestFunc)
+	return fn
+}
+
+// AfterResponse returns the afterResponse function exported by the
+// script, or nil if there is none.
+func (e Exports) AfterResponse() AfterResponseFunc {
+	fn, _ := e["__afterResponse"].(AfterResponseFunc)
+	return fn
+}
+
+// wrapBeforeRequest makes beforeRequest return the request, which it
+// modifies in place, unless it returns false.
+func wrapBeforeRequest(rt *jsRuntime, vm *goja.Runtime) error {
+	fn := rt.funcs["beforeRequest"]
+	if fn == nil {
+		return nil
+	}
+
+	v, err := vm.RunString(`(fn) => async (req) => (await fn(req)) === false ? false : req`)
+	if err != nil {
+		return fmt.Errorf("failed to wrap beforeRequest: %w", err)
+	}
+	wrap, _ := goja.AssertFunction(v)
+
+	rt.funcs["beforeRequest"], err = wrap(goja.Undefined(), fn)
+	return err
+}
+
+// beforeRequest runs on the runtime that sent the request, if it was
+// sent by the script. Waiting for another runtime could deadlock once
+// all of them wait for their requests.
+func (p *runtimePool) beforeRequest(r *Request) (bool, error) {
+	args := func(vm *goja.Runtime) ([]goja.Value, error) {
+		o := vm.NewObject()
+		o.Set("method", r.Method)
+		o.Set("url", r.URL)
+		o.Set("headers", headerObject(vm, r.Headers))
+		o.Set("body", string(r.Body))
+		o.Set("depth", r.Depth)
+		o.Set("meta", r.Data)
+		o.Set("referrer", r.Referrer)
+		return []goja.Value{o}, nil
+	}
+
+	var v any
+	var err error
+	if rt := runtimeFrom(r.ctx); rt != nil && rt.pool == p {
+		v, err = rt.callInUse(rt.funcs["beforeRequest"], args)
+	} else {
+		v, err = p.call("beforeRequest", nil, args)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	req, ok := v.(map[string]any)
+	if !ok {
+		return false, nil
+	}
+
+	if s, ok := req["method"].(string); ok {
+		r.Method = strings.ToUpper(s)
+	}
+	if s, ok := req["url"].(string); ok {
+		r.URL = s
+	}
+	if h, ok := req["headers"].(map[string]any); ok {
+		r.Headers = http.Header{}
+		for k, v := range h {
+			if values, ok := v.([]any); ok {
+				for _, value := range values {
+					r.Headers.Add(k, fmt.Sprint(value))
+				}
+			} else {
+				r.Headers.Add(k, fmt.Sprint(v))
+			}
+		}
+	}
+	if s, ok := req["body"].(string); ok && s != string(r.Body) {
+		r.Body = []byte(s)
+	}
+	return true, nil
+}
+
+func (p *runtimePool) afterResponse(r *Response) (bool, error) {
+	v, err := p.call("afterResponse", nil, func(vm *goja.Runtime) ([]goja.Value, error) {
+		o := vm.NewObject()
+		o.Set("url", r.Request.URL)
+		o.Set("status", r.StatusCode)
+		o.Set("headers", headerObject(vm, r.Headers))
+		o.Set("body", string(r.Body))
+		o.Set("meta", r.Request.Data)
+		return []goja.Value{o}, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return v != false, nil
+}
+
+// headerObject converts headers for the script. Each header is an
+// array of its values.
+func headerObject(vm *goja.Runtime, h http.Header) *goja.Object {
+	o := vm.NewObject()
+	for k, v := range h {
+		values := make([]any, len(v))
+		for i, value := range v {
+			values[i] = value
+		}
+		o.Set(k, vm.NewArray(values...))
+	}
+	return o
+}
+
+// scriptModule bridges the beforeRequest and afterResponse functions of
+// the script into the modules. It is added after all other modules, so
+// that beforeRequest sees the requests as they are sent.
+type scriptModule struct {
+	beforeRequest BeforeRequestFunc
+	afterResponse AfterResponseFunc
+
+	stats *Stats
+}
+
+// withScriptModule adds the module for the beforeRequest and
+// afterResponse functions, if the script exports any of them.
+func withScriptModule(mods []Module, e Exports) []Module {
+	before, after := e.BeforeRequest(), e.AfterResponse()
+	if before == nil && after == nil {
+		return mods
+	}
+	return append(mods, &scriptModule{beforeRequest: before, afterResponse: after})
+}
+
+func (*scriptModule) ModuleInfo() ModuleInfo {
+	return ModuleInfo{ID: "script"}
+}
+
+func (m *scriptModule) Provision(ctx Context) error {
+	m.stats = ctx.Stats()
+	return nil
+}
+
+func (m *scriptModule) BuildRequest(r *Request) {
+	if m.beforeRequest == nil {
+		return
+	}
+
+	ok, err := m.beforeRequest(r)
+	if err != nil {
+		slog.Error("beforeRequest function failed", "url", r.URL, "error", err)
+		m.stats.Add(StatErrors+".script", 1)
+		r.skip = "beforeRequest failed"
+		return
+	}
+	if !ok {
+		r.skip = "beforeRequest returned false"
+	}
+}
+
+func (m *scriptModule) ValidateRequest(r *Request) (bool, string) {
+	if r.skip != "" {
+		return false, r.skip
+	}
+	return true, ""
+}
+
+func (m *scriptModule) ValidateResponse(r *Response) (bool, string) {
+	if m.afterResponse == nil {
+		return true, ""
+	}
+
+	ok, err := m.afterResponse(r)
+	if err != nil {
+		slog.Error("afterResponse function failed", "url", r.Request.URL, "error", err)
+		m.stats.Add(StatErrors+".script", 1)
+		return false, "afterResponse failed"
+	}
+	if !ok {
+		return false, "afterResponse returned false"
+	}
+	return true, ""
+}
+
+var (
+	_ Provisioner       = (*scriptModule)(nil)
+	_ RequestBuilder    = (*scriptModule)(nil)
+	_ RequestValidator  = (*scriptModule)(nil)
+	_ ResponseValidator = (*scriptModule)(nil)
+)
@@ -9,7 +9,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"mime"
 	"net/http"
 	gourl "net/url"
@@ -34,9 +34,9 @@ func NewJSLibrary(client *http.Client) (imports Imports, wait func()) {
 			"parse": jsParse(),
 		},
 		"flyscrape/http": map[string]any{
-			"get":      jsHTTPGet(client),
-			"postForm": jsHTTPPostForm(client),
-			"postJSON": jsHTTPPostJSON(client),
+			"get":      Async(jsHTTPGet(client)),
+			"postForm": Async(jsHTTPPostForm(client)),
+			"postJSON": Async(jsHTTPPostJSON(client)),
 			"download": jsHTTPDownload(client, downloads),
 		},
 	}
@@ -143,26 +143,26 @@ func jsHTTPDownload(client *http.Client, g *errgroup.Group) func(url string, dst
 		g.Go(func() error {
 			req, err := http.NewRequest("GET", url, nil)
 			if err != nil {
-				log.Printf("error downloading file %q: %v", url, err)
+				slog.Error("failed to download file", "url", url, "error", err)
 				return nil
 			}
 			req.Header.Add(HeaderBypassCache, "true")
 
 			resp, err := client.Do(req)
 			if err != nil {
-				log.Printf("error downloading file %q: %v", url, err)
+				slog.Error("failed to download file", "url", url, "error", err)
 				return nil
 			}
 			defer resp.Body.Close()
 
 			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-				log.Printf("error downloading file %q: unexpected status code %d", url, resp.StatusCode)
+				slog.Error("failed to download file", "url", url, "status", resp.StatusCode)
 				return nil
 			}
 
 			dst, err = filepath.Abs(dst)
 			if err != nil {
-				log.Printf("error downloading file %q: abs path failed: %v", url, err)
+				slog.Error("failed to download file", "url", url, "error", err)
 				return nil
 			}
 
@@ -178,7 +178,7 @@ func jsHTTPDownload(client *http.Client, g *errgroup.Group) func(url string, dst
 			os.MkdirAll(filepath.Dir(dst), 0o755)
 			f, err := os.Create(dst)
 			if err != nil {
-				log.Printf("error downloading file %q: file save failed: %v", url, err)
+				slog.Error("failed to save downloaded file", "url", url, "file", dst, "error", err)
 				return nil
 			}
 			defer f.Close()
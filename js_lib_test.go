@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"os"
+	"sync"
 	"sync/atomic"
 	"testing"
 
@@ -42,14 +43,9 @@ func TestJSLibHTTPGet(t *testing.T) {
 	script := `
     import http from "flyscrape/http"
 
-    const res = http.get("https://example.com")
-
-    export const body = res.body;
-    export const status = res.status;
-    export const error = res.error;
-    export const headers = res.headers;
-
-    export default function () {}
+    export default async function () {
+        return await http.get("https://example.com");
+    }
     `
 
 	client := &http.Client{
@@ -60,39 +56,72 @@ func TestJSLibHTTPGet(t *testing.T) {
 	exports, err := flyscrape.Compile(script, imports)
 	require.NoError(t, err)
 
-	body, ok := exports["body"].(string)
+	result, err := exports.Scrape(flyscrape.ScrapeParams{URL: "http://localhost/"})
+	require.NoError(t, err)
+	res := result.(map[string]any)
+
+	body, ok := res["body"].(string)
 	require.True(t, ok)
 	require.Equal(t, html, body)
 
-	status, ok := exports["status"].(int64)
+	status, ok := res["status"].(float64)
 	require.True(t, ok)
-	require.Equal(t, int64(200), status)
+	require.Equal(t, float64(200), status)
 
-	error, ok := exports["error"].(string)
+	error, ok := res["error"].(string)
 	require.True(t, ok)
 	require.Equal(t, "", error)
 
-	headers, ok := exports["headers"].(map[string]any)
+	headers, ok := res["headers"].(map[string]any)
 	require.True(t, ok)
 	require.NotEmpty(t, headers)
 }
 
-func TestJSLibHTTPPostForm(t *testing.T) {
+func TestJSLibHTTPGetParallel(t *testing.T) {
 	script := `
-    import http from "flyscrape/http"
+    import http from "flyscrape/http"
+
+    export default async function () {
+        const [a, b] = await Promise.all([
+            http.get("https://example.com/a"),
+            http.get("https://example.com/b"),
+        ]);
+        return [a.body, b.body];
+    }
+    `
 
-    const res = http.postForm("https://example.com", {
-        username: "foo",
-        password: "bar",
-        arr: [1,2,3],
-    })
+	// Both responses are only sent once both requests are in flight.
+	var inflight sync.WaitGroup
+	inflight.Add(2)
 
-    export const body = res.body;
-    export const status = res.status;
-    export const error = res.error;
-    export const headers = res.headers;
+	client := &http.Client{
+		Transport: flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+			inflight.Done()
+			inflight.Wait()
+			return flyscrape.MockResponse(200, r.URL.Path)
+		}),
+	}
 
-    export default function () {}
+	imports, _ := flyscrape.NewJSLibrary(client)
+	exports, err := flyscrape.Compile(script, imports)
+	require.NoError(t, err)
+
+	result, err := exports.Scrape(flyscrape.ScrapeParams{URL: "http://localhost/"})
+	require.NoError(t, err)
+	require.Equal(t, []any{"/a", "/b"}, result)
+}
+
+func TestJSLibHTTPPostForm(t *testing.T) {
+	script := `
+    import http from "flyscrape/http"
+
+    export default async function () {
+        return await http.postForm("https://example.com", {
+            username: "foo",
+            password: "bar",
+            arr: [1,2,3],
+        });
+    }
     `
 
 	client := &http.Client{
@@ -111,19 +140,23 @@ func TestJSLibHTTPPostForm(t *testing.T) {
 	exports, err := flyscrape.Compile(script, imports)
 	require.NoError(t, err)
 
-	body, ok := exports["body"].(string)
+	result, err := exports.Scrape(flyscrape.ScrapeParams{URL: "http://localhost/"})
+	require.NoError(t, err)
+	res := result.(map[string]any)
+
+	body, ok := res["body"].(string)
 	require.True(t, ok)
 	require.Equal(t, "Bad Request", body)
 
-	status, ok := exports["status"].(int64)
+	status, ok := res["status"].(float64)
 	require.True(t, ok)
-	require.Equal(t, int64(400), status)
+	require.Equal(t, float64(400), status)
 
-	error, ok := exports["error"].(string)
+	error, ok := res["error"].(string)
 	require.True(t, ok)
 	require.Equal(t, "", error)
 
-	headers, ok := exports["headers"].(map[string]any)
+	headers, ok := res["headers"].(map[string]any)
 	require.True(t, ok)
 	require.NotEmpty(t, headers)
 }
@@ -132,17 +165,12 @@ func TestJSLibHTTPPostJSON(t *testing.T) {
 	script := `
     import http from "flyscrape/http"
 
-    const res = http.postJSON("https://example.com", {
-        username: "foo",
-        password: "bar",
-    })
-
-    export const body = res.body;
-    export const status = res.status;
-    export const error = res.error;
-    export const headers = res.headers;
-
-    export default function () {}
+    export default async function () {
+        return await http.postJSON("https://example.com", {
+            username: "foo",
+            password: "bar",
+        });
+    }
     `
 
 	client := &http.Client{
@@ -163,19 +191,23 @@ func TestJSLibHTTPPostJSON(t *testing.T) {
 	exports, err := flyscrape.Compile(script, imports)
 	require.NoError(t, err)
 
-	body, ok := exports["body"].(string)
+	result, err := exports.Scrape(flyscrape.ScrapeParams{URL: "http://localhost/"})
+	require.NoError(t, err)
+	res := result.(map[string]any)
+
+	body, ok := res["body"].(string)
 	require.True(t, ok)
 	require.Equal(t, "Bad Request", body)
 
-	status, ok := exports["status"].(int64)
+	status, ok := res["status"].(float64)
 	require.True(t, ok)
-	require.Equal(t, int64(400), status)
+	require.Equal(t, float64(400), status)
 
-	error, ok := exports["error"].(string)
+	error, ok := res["error"].(string)
 	require.True(t, ok)
 	require.Equal(t, "", error)
 
-	headers, ok := exports["headers"].(map[string]any)
+	headers, ok := res["headers"].(map[string]any)
 	require.True(t, ok)
 	require.NotEmpty(t, headers)
 }
@@ -5,8 +5,14 @@
 package flyscrape_test
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/dop251/goja"
 	"github.com/philippta/flyscrape"
@@ -219,7 +225,7 @@ func TestJSScrapeParamScrape(t *testing.T) {
 	result, err := exports.Scrape(flyscrape.ScrapeParams{
 		HTML: html,
 		URL:  "http://localhost/",
-		Process: func(url string) ([]byte, error) {
+		Process: func(ctx context.Context, url string) ([]byte, error) {
 			return nil, nil
 		},
 	})
@@ -232,11 +238,11 @@ func TestJSScrapeParamScrape(t *testing.T) {
 
 func TestJSScrapeParamScrapeDeep(t *testing.T) {
 	js := `
-    export default function({ scrape }) {
-        return scrape("/foo/", function({ url, scrape }) {
+    export default async function({ scrape }) {
+        return await scrape("/foo/", async function({ url, scrape }) {
 		return {
 			url: url,
-			deep: scrape("bar", function({ url }) {
+			deep: await scrape("bar", function({ url }) {
 				return url;
 			}),
 		};
@@ -249,7 +255,7 @@ func TestJSScrapeParamScrapeDeep(t *testing.T) {
 	result, err := exports.Scrape(flyscrape.ScrapeParams{
 		HTML: html,
 		URL:  "http://localhost/",
-		Process: func(url string) ([]byte, error) {
+		Process: func(ctx context.Context, url string) ([]byte, error) {
 			return nil, nil
 		},
 	})
@@ -260,6 +266,74 @@ func TestJSScrapeParamScrapeDeep(t *testing.T) {
 	}, result)
 }
 
+func TestJSScrapeParamScrapeParallel(t *testing.T) {
+	js := `
+    export default async function({ scrape }) {
+        const pages = await Promise.all(["/a", "/b"].map((url) => scrape(url, ({ url }) => url)));
+        return { pages };
+    }
+    `
+	exports, err := flyscrape.Compile(js, nil)
+	require.NoError(t, err)
+
+	// Both pages are only returned once both of them are requested.
+	var requested sync.WaitGroup
+	requested.Add(2)
+
+	result, err := exports.Scrape(flyscrape.ScrapeParams{
+		HTML: html,
+		URL:  "http://localhost/",
+		Process: func(ctx context.Context, url string) ([]byte, error) {
+			requested.Done()
+			requested.Wait()
+			return nil, nil
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"pages": []any{"http://localhost/a", "http://localhost/b"},
+	}, result)
+}
+
+func TestJSScrapeAsyncError(t *testing.T) {
+	js := `
+    export default async function() {
+        await null;
+        throw new Error("failed");
+    }
+    `
+	exports, err := flyscrape.Compile(js, nil)
+	require.NoError(t, err)
+
+	_, err = exports.Scrape(flyscrape.ScrapeParams{HTML: html, URL: "http://localhost/"})
+	require.ErrorContains(t, err, "failed")
+}
+
+func TestJSScrapeNotAwaited(t *testing.T) {
+	js := `
+    export default function({ scrape }) {
+        return { page: scrape("/foo", ({ url }) => url) };
+    }
+    `
+	exports, err := flyscrape.Compile(js, nil)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	_, err = exports.Scrape(flyscrape.ScrapeParams{
+		HTML: html,
+		URL:  "http://localhost/",
+		Process: func(ctx context.Context, url string) ([]byte, error) {
+			time.Sleep(10 * time.Millisecond)
+			return nil, nil
+		},
+	})
+	require.ErrorContains(t, err, `result contains a Promise at "page", it has to be awaited`)
+	require.Contains(t, buf.String(), "a Promise was not awaited")
+}
+
 func TestJSScrapeParamFollow(t *testing.T) {
 	js := `
     export default function({ follow }) {
@@ -273,14 +347,199 @@ func TestJSScrapeParamFollow(t *testing.T) {
 	_, err = exports.Scrape(flyscrape.ScrapeParams{
 		HTML: html,
 		URL:  "http://localhost/",
-		Follow: func(url string) {
-			followedURL = url
+		Follow: func(f flyscrape.FollowRequest) {
+			followedURL = f.URL
 		},
 	})
 	require.NoError(t, err)
 	require.Equal(t, "http://localhost/foo", followedURL)
 }
 
+func TestJSScrapeParamFollowRequest(t *testing.T) {
+	js := `
+    export default function({ follow }) {
+        follow({
+            url: "/search",
+            method: "post",
+            body: "q=flyscrape",
+            headers: { "Content-Type": "application/x-www-form-urlencoded" },
+        })
+        follow({ url: "/api", method: "POST", body: { page: 2 } })
+    }
+    `
+	exports, err := flyscrape.Compile(js, nil)
+	require.NoError(t, err)
+
+	var followed []flyscrape.FollowRequest
+	_, err = exports.Scrape(flyscrape.ScrapeParams{
+		HTML: html,
+		URL:  "http://localhost/",
+		Follow: func(f flyscrape.FollowRequest) {
+			followed = append(followed, f)
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []flyscrape.FollowRequest{
+		{
+			URL:     "http://localhost/search",
+			Method:  "post",
+			Body:    []byte("q=flyscrape"),
+			Headers: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+		},
+		{
+			URL:     "http://localhost/api",
+			Method:  "POST",
+			Body:    []byte(`{"page":2}`),
+			Headers: http.Header{"Content-Type": {"application/json"}},
+		},
+	}, followed)
+}
+
+func TestJSPriority(t *testing.T) {
+	js := `
+    export default function() {}
+    export function priority(url, depth) {
+        return url.includes("/products/") ? 10 - depth : -depth;
+    }
+    `
+	exports, err := flyscrape.Compile(js, nil)
+	require.NoError(t, err)
+
+	priority := exports.Priority()
+	require.NotNil(t, priority)
+	require.Equal(t, float64(8), priority("http://localhost/products/1", 2))
+	require.Equal(t, float64(-2), priority("http://localhost/about", 2))
+}
+
+func TestJSPriorityUndefined(t *testing.T) {
+	js := `
+    export default function() {}
+    `
+	exports, err := flyscrape.Compile(js, nil)
+	require.NoError(t, err)
+	require.Nil(t, exports.Priority())
+}
+
+func TestJSLifecycle(t *testing.T) {
+	js := `
+    export function setup() {
+        return { token: "secret" };
+    }
+    export default function() {}
+    export async function teardown(summary, setup) {
+        if (summary.pages !== 3 || setup.token !== "secret") {
+            throw new Error("unexpected arguments");
+        }
+    }
+    export function onError({ url, status, attempt, meta, retry }) {
+        if (attempt === 0) {
+            retry();
+            return;
+        }
+        return { url, status, meta };
+    }
+    `
+	exports, err := flyscrape.Compile(js, nil)
+	require.NoError(t, err)
+
+	setup, err := exports.Setup()(nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"token": "secret"}, setup)
+
+	require.NoError(t, exports.Teardown()(flyscrape.Summary{Pages: 3}, setup, nil))
+
+	var retried bool
+	data, err := exports.OnError()(flyscrape.ErrorParams{
+		URL:    "http://localhost/",
+		Status: 500,
+		Retry:  func() { retried = true },
+	})
+	require.NoError(t, err)
+	require.Nil(t, data)
+	require.True(t, retried)
+
+	data, err = exports.OnError()(flyscrape.ErrorParams{
+		URL:     "http://localhost/",
+		Status:  500,
+		Attempt: 1,
+		Meta:    "foo",
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"url": "http://localhost/", "status": float64(500), "meta": "foo"}, data)
+}
+
+func TestJSLifecycleUndefined(t *testing.T) {
+	js := `
+    export default function() {}
+    `
+	exports, err := flyscrape.Compile(js, nil)
+	require.NoError(t, err)
+	require.Nil(t, exports.Setup())
+	require.Nil(t, exports.Teardown())
+	require.Nil(t, exports.OnError())
+}
+
+func TestJSRuntimesParallel(t *testing.T) {
+	js := `
+    import { wait } from "flyscrape/test"
+    export default function() {
+        wait();
+        return true;
+    }
+    `
+
+	// Both calls only return once both of them are running.
+	var running sync.WaitGroup
+	running.Add(2)
+	imports := flyscrape.Imports{
+		"flyscrape/test": map[string]any{
+			"wait": func() {
+				running.Done()
+				running.Wait()
+			},
+		},
+	}
+
+	exports, err := flyscrape.Compile(js, imports)
+	require.NoError(t, err)
+	exports.SetRuntimes(2)
+
+	results := make(chan any, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			result, _ := exports.Scrape(flyscrape.ScrapeParams{URL: "http://localhost/"})
+			results <- result
+		}()
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case result := <-results:
+			require.Equal(t, true, result)
+		case <-time.After(5 * time.Second):
+			t.Fatal("scrape calls did not run in parallel")
+		}
+	}
+}
+
+func TestJSRuntimesModuleState(t *testing.T) {
+	js := `
+    let count = 0;
+    export default function() {
+        return ++count;
+    }
+    `
+	exports, err := flyscrape.Compile(js, nil)
+	require.NoError(t, err)
+	exports.SetRuntimes(1)
+
+	for i := 1; i <= 3; i++ {
+		result, err := exports.Scrape(flyscrape.ScrapeParams{URL: "http://localhost/"})
+		require.NoError(t, err)
+		require.Equal(t, float64(i), result)
+	}
+}
+
 func TestJSCompileError(t *testing.T) {
 	exports, err := flyscrape.Compile("import foo;", nil)
 	require.Error(t, err)
@@ -389,3 +648,117 @@ func TestJSArbitraryConstString(t *testing.T) {
 
 	require.Equal(t, "bar", exports["foo"].(string))
 }
+
+func TestJSScrapeParamFollowData(t *testing.T) {
+	js := `
+    export default function({ follow }) {
+        follow("/foo", { data: { category: "books", position: 1 } })
+        follow({ url: "/bar", data: ["a", "b"] })
+    }
+    `
+	exports, err := flyscrape.Compile(js, nil)
+	require.NoError(t, err)
+
+	var followed []flyscrape.FollowRequest
+	_, err = exports.Scrape(flyscrape.ScrapeParams{
+		HTML: html,
+		URL:  "http://localhost/",
+		Follow: func(f flyscrape.FollowRequest) {
+			followed = append(followed, f)
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, followed, 2)
+	require.Equal(t, "http://localhost/foo", followed[0].URL)
+	require.Equal(t, map[string]any{"category": "books", "position": float64(1)}, followed[0].Data)
+	require.Equal(t, "http://localhost/bar", followed[1].URL)
+	require.Equal(t, []any{"a", "b"}, followed[1].Data)
+}
+
+func TestJSScrapeParamMeta(t *testing.T) {
+	js := `
+    export default function({ meta, referrer }) {
+        return { category: meta.category, referrer }
+    }
+    `
+	exports, err := flyscrape.Compile(js, nil)
+	require.NoError(t, err)
+
+	result, err := exports.Scrape(flyscrape.ScrapeParams{
+		HTML:     html,
+		URL:      "http://localhost/foo",
+		Meta:     map[string]any{"category": "books"},
+		Referrer: "http://localhost/",
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"category": "books",
+		"referrer": "http://localhost/",
+	}, result)
+}
+
+func TestJSBeforeRequest(t *testing.T) {
+	js := `
+    export default function() {}
+    export function beforeRequest(req) {
+        if (req.meta.skip) {
+            return false;
+        }
+        req.method = "post";
+        req.url += "?page=" + req.depth;
+        req.headers["X-Token"] = "secret";
+        req.headers["X-Multi"].push("b");
+        delete req.headers["X-Remove"];
+        req.body = "a=1";
+    }
+    `
+	exports, err := flyscrape.Compile(js, nil)
+	require.NoError(t, err)
+
+	req := &flyscrape.Request{
+		Method:  "GET",
+		URL:     "http://localhost/",
+		Headers: http.Header{"X-Keep": {"1"}, "X-Multi": {"a"}, "X-Remove": {"1"}},
+		Depth:   2,
+		Data:    map[string]any{"skip": false},
+	}
+	ok, err := exports.BeforeRequest()(req)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "POST", req.Method)
+	require.Equal(t, "http://localhost/?page=2", req.URL)
+	require.Equal(t, http.Header{"X-Keep": {"1"}, "X-Multi": {"a", "b"}, "X-Token": {"secret"}}, req.Headers)
+	require.Equal(t, []byte("a=1"), req.Body)
+
+	ok, err = exports.BeforeRequest()(&flyscrape.Request{URL: "http://localhost/", Data: map[string]any{"skip": true}})
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestJSAfterResponse(t *testing.T) {
+	js := `
+    export default function() {}
+    export async function afterResponse(res) {
+        return res.headers["Content-Type"][0] === "text/html";
+    }
+    `
+	exports, err := flyscrape.Compile(js, nil)
+	require.NoError(t, err)
+	require.Nil(t, exports.BeforeRequest())
+
+	ok, err := exports.AfterResponse()(&flyscrape.Response{
+		StatusCode: 200,
+		Headers:    http.Header{"Content-Type": {"text/html"}},
+		Request:    &flyscrape.Request{URL: "http://localhost/"},
+	})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = exports.AfterResponse()(&flyscrape.Response{
+		StatusCode: 200,
+		Headers:    http.Header{"Content-Type": {"application/pdf"}},
+		Request:    &flyscrape.Request{URL: "http://localhost/"},
+	})
+	require.NoError(t, err)
+	require.False(t, ok)
+}
@@ -0,0 +1,98 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+// runSetup calls the setup function of the script, if any. Its result
+// is passed to all later calls into the script.
+func (s *Scraper) runSetup(ctx context.Context) error {
+	if s.SetupFunc == nil {
+		return nil
+	}
+
+	setup, err := s.SetupFunc(func(req *http.Request) (*http.Response, error) {
+		return s.fetch(ctx, req)
+	})
+	if err != nil {
+		return err
+	}
+	s.setup = setup
+	return nil
+}
+
+// runTeardown calls the teardown function of the script, if any, with
+// the stats of the run. Its requests are not canceled along with ctx,
+// so that it can clean up after an interrupted run as well.
+func (s *Scraper) runTeardown(ctx context.Context) {
+	if s.TeardownFunc == nil {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	err := s.TeardownFunc(s.stats.Summary(), s.setup, func(req *http.Request) (*http.Response, error) {
+		return s.fetch(ctx, req)
+	})
+	if err != nil {
+		slog.Error("teardown function failed", "error", err)
+		s.stats.Add(StatErrors+".script", 1)
+	}
+}
+
+// handleError calls the onError function of the script for a failed
+// response. A returned value becomes the data of the response. It
+// reports whether the function scheduled a retry of the job, in which
+// case the failed response is dropped.
+func (s *Scraper) handleError(ctx context.Context, job target, response *Response) (retried bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("onError function panicked", "url", job.url, "error", r)
+		}
+	}()
+
+	data, err := s.ErrorFunc(ErrorParams{
+		URL:     response.Request.URL,
+		Status:  response.StatusCode,
+		Error:   response.Error.Error(),
+		Attempt: job.attempt,
+		Meta:    job.data,
+		Setup:   s.setup,
+		Retry: func() {
+			retried = true
+		},
+		Fetch: func(req *http.Request) (*http.Response, error) {
+			return s.fetch(ctx, req)
+		},
+	})
+	if err != nil {
+		slog.Error("onError function failed", "url", job.url, "error", err)
+		s.stats.Add(StatErrors+".script", 1)
+	}
+
+	if retried {
+		slog.Debug("retrying url", "url", job.url, "attempt", job.attempt+1)
+		s.retryJob(job)
+		return true
+	}
+	if data != nil {
+		response.Data = data
+	}
+	return false
+}
+
+// retryJob enqueues a job once more, although its URL was visited. Its
+// request is built anew.
+func (s *Scraper) retryJob(job target) {
+	job.attempt++
+	job.built = nil
+	if s.State != nil {
+		s.State.addPending(&job)
+	}
+	s.push(job)
+}
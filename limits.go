@@ -0,0 +1,121 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Duration is a time.Duration that is configured as a string like "1h30m",
+// or as a number of seconds.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var secs float64
+	if err := json.Unmarshal(b, &secs); err == nil {
+		*d = Duration(secs * float64(time.Second))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.New("expected duration string or number of seconds")
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// startLimits starts the timer of the maximum duration. The returned
+// function stops it.
+func (s *Scraper) startLimits() func() {
+	if s.Options.MaxDuration <= 0 {
+		return func() {}
+	}
+
+	t := time.AfterFunc(time.Duration(s.Options.MaxDuration), func() {
+		s.stop("maxDuration")
+	})
+	return func() { t.Stop() }
+}
+
+// stop stops the run once a limit is reached, see halt.
+func (s *Scraper) stop(limit string) {
+	if s.halt() {
+		slog.Info("limit reached, stopping", "limit", limit)
+	}
+}
+
+// halt stops the run. No new jobs are accepted and the queued ones are
+// dropped, while in-flight requests finish. It reports whether the run
+// was still going.
+func (s *Scraper) halt() (first bool) {
+	s.stopOnce.Do(func() {
+		first = true
+		s.stopped.Store(true)
+		s.unparkAll()
+	})
+	return first
+}
+
+// reserve counts a page towards the page limits before it is fetched.
+// It reports false if the page exceeds any of them.
+func (s *Scraper) reserve(url string) bool {
+	if s.stopped.Load() {
+		return false
+	}
+
+	if max := s.Options.MaxPagesPerDomain; max > 0 {
+		host := hostname(url)
+
+		s.domainMu.Lock()
+		if s.domainPages[host] >= max {
+			s.domainMu.Unlock()
+			return false
+		}
+		s.domainPages[host]++
+		s.domainMu.Unlock()
+	}
+
+	if max := s.Options.MaxPages; max > 0 {
+		n := s.pages.Add(1)
+		if n > int64(max) {
+			return false
+		}
+		if n == int64(max) {
+			s.stop("maxPages")
+		}
+	}
+
+	return true
+}
+
+// accepts reports whether a URL can still be queued.
+func (s *Scraper) accepts(url string) bool {
+	if s.stopped.Load() {
+		return false
+	}
+
+	if max := s.Options.MaxPagesPerDomain; max > 0 {
+		s.domainMu.Lock()
+		defer s.domainMu.Unlock()
+		return s.domainPages[hostname(url)] < max
+	}
+	return true
+}
+
+// download counts downloaded bytes towards the byte limit.
+func (s *Scraper) download(n int) {
+	s.stats.Add(StatBytes, int64(n))
+	if max := s.Options.MaxBytes; max > 0 && s.bytes.Add(int64(n)) >= max {
+		s.stop("maxBytes")
+	}
+}
@@ -0,0 +1,129 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/philippta/flyscrape"
+	"github.com/philippta/flyscrape/modules/followlinks"
+	"github.com/philippta/flyscrape/modules/hook"
+	"github.com/philippta/flyscrape/modules/starturl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitsMaxPages(t *testing.T) {
+	urls, finalized := runEndless(t, flyscrape.Options{MaxPages: 10}, "http://www.example.com/")
+	require.Len(t, urls, 10)
+	require.True(t, finalized)
+}
+
+func TestLimitsMaxPagesPerDomain(t *testing.T) {
+	urls, _ := runEndless(t, flyscrape.Options{MaxPagesPerDomain: 3},
+		"http://a.example.com/",
+		"http://b.example.com/",
+	)
+
+	hosts := map[string]int{}
+	for _, u := range urls {
+		pu, err := url.Parse(u)
+		require.NoError(t, err)
+		hosts[pu.Host]++
+	}
+	require.Equal(t, map[string]int{"a.example.com": 3, "b.example.com": 3}, hosts)
+}
+
+func TestLimitsMaxBytes(t *testing.T) {
+	urls, finalized := runEndless(t, flyscrape.Options{MaxBytes: 1000, Workers: 1}, "http://www.example.com/")
+	require.True(t, finalized)
+
+	// The run stops with the page that exceeds the limit.
+	var bytes int
+	for i, u := range urls {
+		pu, err := url.Parse(u)
+		require.NoError(t, err)
+
+		if i == len(urls)-1 {
+			require.Less(t, bytes, 1000)
+		}
+		bytes += len(endlessPage(pu.Path))
+	}
+	require.GreaterOrEqual(t, bytes, 1000)
+}
+
+func TestLimitsMaxDuration(t *testing.T) {
+	opts := flyscrape.Options{MaxDuration: flyscrape.Duration(50 * time.Millisecond)}
+
+	defer slog.SetDefault(slog.Default())
+
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	// The site is endless, so the run only ends once a limit is reached.
+	urls, finalized := runEndless(t, opts, "http://www.example.com/")
+
+	require.NotEmpty(t, urls)
+	require.True(t, finalized)
+	require.Contains(t, buf.String(), `msg="limit reached, stopping" limit=maxDuration`)
+}
+
+func TestLimitsDurationJSON(t *testing.T) {
+	var opts flyscrape.Options
+	require.NoError(t, json.Unmarshal([]byte(`{"maxDuration": "1h30m"}`), &opts))
+	require.Equal(t, flyscrape.Duration(90*time.Minute), opts.MaxDuration)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"maxDuration": 1.5}`), &opts))
+	require.Equal(t, flyscrape.Duration(1500*time.Millisecond), opts.MaxDuration)
+
+	require.Error(t, json.Unmarshal([]byte(`{"maxDuration": "soon"}`), &opts))
+}
+
+// runEndless crawls a site where every page links to five new pages.
+func runEndless(t *testing.T, opts flyscrape.Options, start ...string) (urls []string, finalized bool) {
+	var mu sync.Mutex
+
+	scraper := flyscrape.NewScraper()
+	scraper.Options = opts
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URLs: start},
+		&followlinks.Module{},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					mu.Lock()
+					urls = append(urls, r.URL.String())
+					mu.Unlock()
+
+					time.Sleep(5 * time.Millisecond)
+					return flyscrape.MockResponse(200, endlessPage(r.URL.Path))
+				})
+			},
+			FinalizeFn: func() {
+				finalized = true
+			},
+		},
+	}
+	scraper.Run(context.Background())
+
+	return urls, finalized
+}
+
+func endlessPage(path string) string {
+	var links strings.Builder
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(&links, `<a href="%s/%d">%d</a>`, strings.TrimSuffix(path, "/"), i, i)
+	}
+	return links.String()
+}
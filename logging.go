@@ -0,0 +1,78 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Log formats for SetupLogging.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// logOutput is where log messages are written. The progress view
+// swaps it out to print them above itself.
+var logOutput = &switchWriter{w: os.Stderr}
+
+// SetupLogging sets the default logger to write messages of at least the
+// given level, one of debug, info, warn or error, to stderr. The format
+// is either LogFormatText or LogFormatJSON.
+func SetupLogging(level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch format {
+	case LogFormatText, "":
+		opts.ReplaceAttr = omitTime
+		h = slog.NewTextHandler(logOutput, opts)
+	case LogFormatJSON:
+		h = slog.NewJSONHandler(logOutput, opts)
+	default:
+		return fmt.Errorf("invalid log format %q", format)
+	}
+
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// omitTime removes the time from text logs, which are read by humans
+// as the run goes.
+func omitTime(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return a
+}
+
+type switchWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// swap replaces the writer and returns the previous one.
+func (s *switchWriter) swap(w io.Writer) io.Writer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.w
+	s.w = w
+	return prev
+}
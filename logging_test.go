@@ -0,0 +1,28 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/philippta/flyscrape"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetupLogging(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	require.NoError(t, flyscrape.SetupLogging("debug", flyscrape.LogFormatJSON))
+	require.True(t, slog.Default().Enabled(context.Background(), slog.LevelDebug))
+
+	require.NoError(t, flyscrape.SetupLogging("WARN", flyscrape.LogFormatText))
+	require.False(t, slog.Default().Enabled(context.Background(), slog.LevelInfo))
+	require.True(t, slog.Default().Enabled(context.Background(), slog.LevelWarn))
+
+	require.Error(t, flyscrape.SetupLogging("verbose", flyscrape.LogFormatText))
+	require.Error(t, flyscrape.SetupLogging("info", "xml"))
+}
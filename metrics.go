@@ -0,0 +1,155 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// serveMetricsOn serves the metrics on addr until the returned function
+// is called.
+func (s *Scraper) serveMetricsOn(addr string) (stop func(), err error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &http.Server{Handler: s.MetricsHandler()}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("metrics: failed to serve", "error", err)
+		}
+	}()
+
+	return func() {
+		srv.Shutdown(context.Background())
+	}, nil
+}
+
+// MetricsHandler serves the metrics of the scraper in the Prometheus
+// text format on /metrics and its progress as JSON on /status.
+func (s *Scraper) MetricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", s.serveMetrics)
+	mux.HandleFunc("GET /status", s.serveStatus)
+	return mux
+}
+
+// Status is the state of a run as served on /status.
+type Status struct {
+	Running  bool     `json:"running"`
+	Progress Progress `json:"progress"`
+	Summary  Summary  `json:"summary"`
+}
+
+func (s *Scraper) serveStatus(w http.ResponseWriter, r *http.Request) {
+	status := Status{
+		Running:  s.stats.Running(),
+		Progress: s.Progress(),
+		Summary:  s.stats.Summary(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(status)
+}
+
+func (s *Scraper) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	sum := s.stats.Summary()
+	progress := s.Progress()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	writeMetric(w, "flyscrape_pages_total", "counter", "Pages scraped.", sum.Pages)
+	writeMetric(w, "flyscrape_requests_total", "counter", "Requests sent, including cached ones.", sum.Requests)
+	writeLabeledMetric(w, "flyscrape_responses_total", "counter", "Responses by status class.", "class", sum.Responses)
+	writeLabeledMetric(w, "flyscrape_errors_total", "counter", "Errors by type.", "type", sum.Errors)
+	writeLabeledMetric(w, "flyscrape_filtered_total", "counter", "URLs filtered out by module.", "module", sum.Filtered)
+	writeMetric(w, "flyscrape_bytes_total", "counter", "Bytes downloaded.", sum.Bytes)
+	writeMetric(w, "flyscrape_enqueued_total", "counter", "URLs queued.", sum.Enqueued)
+	writeMetric(w, "flyscrape_dropped_total", "counter", "URLs that could not be queued.", sum.Dropped)
+	writeMetric(w, "flyscrape_retries_total", "counter", "Retried requests.", sum.Retries)
+	writeMetric(w, "flyscrape_cache_hits_total", "counter", "Responses served from the cache.", sum.CacheHits)
+	writeMetric(w, "flyscrape_duplicates_total", "counter", "Pages collapsed as duplicates.", sum.Duplicates)
+
+	var ratio float64
+	if sum.Requests > 0 {
+		ratio = float64(sum.CacheHits) / float64(sum.Requests)
+	}
+	writeMetric(w, "flyscrape_cache_hit_ratio", "gauge", "Ratio of requests served from the cache.", ratio)
+
+	writeMetric(w, "flyscrape_queue_depth", "gauge", "Jobs waiting to be processed.", progress.Queued)
+	writeMetric(w, "flyscrape_in_flight", "gauge", "Jobs being processed.", progress.InFlight)
+
+	writeHistogram(w, "flyscrape_request_duration_seconds", "Time until the response headers arrive, by host.",
+		"host", s.stats.Histograms(StatRequestDuration))
+	writeHistogram(w, "flyscrape_script_duration_seconds", "Time spent in the scrape function.",
+		"", s.stats.Histograms(StatScriptDuration))
+}
+
+func writeMetric[T int | int64 | float64](w io.Writer, name, typ, help string, v T) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	fmt.Fprintf(w, "%s %v\n", name, v)
+}
+
+func writeLabeledMetric(w io.Writer, name, typ, help, labelName string, values map[string]int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, label(labelName, key), values[key])
+	}
+}
+
+func writeHistogram(w io.Writer, name, help, labelName string, hs map[string]Histogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+
+	for _, key := range sortedHistogramKeys(hs) {
+		h := hs[key]
+
+		labels := ""
+		if labelName != "" {
+			labels = label(labelName, key) + ","
+		}
+
+		var cumulative int64
+		for i, bound := range durationBuckets {
+			cumulative += h.Counts[i]
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			fmt.Fprintf(w, "%s_bucket{%s%s} %d\n", name, labels, label("le", le), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", name, labels, h.Count)
+
+		labels = strings.TrimSuffix(labels, ",")
+		if labels != "" {
+			labels = "{" + labels + "}"
+		}
+		fmt.Fprintf(w, "%s_sum%s %v\n", name, labels, h.Sum)
+		fmt.Fprintf(w, "%s_count%s %d\n", name, labels, h.Count)
+	}
+}
+
+func sortedHistogramKeys(hs map[string]Histogram) []string {
+	counts := make(map[string]int64, len(hs))
+	for k, h := range hs {
+		counts[k] = h.Count
+	}
+	return sortedKeys(counts)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// label formats a label with an escaped value.
+func label(name, value string) string {
+	return name + `="` + labelEscaper.Replace(value) + `"`
+}
@@ -0,0 +1,101 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/philippta/flyscrape"
+	"github.com/philippta/flyscrape/modules/followlinks"
+	"github.com/philippta/flyscrape/modules/hook"
+	"github.com/philippta/flyscrape/modules/starturl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	scraper := runMetrics()
+
+	rec := httptest.NewRecorder()
+	scraper.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	require.Contains(t, body, "# TYPE flyscrape_requests_total counter\nflyscrape_requests_total 2\n")
+	require.Contains(t, body, `flyscrape_responses_total{class="2xx"} 1`)
+	require.Contains(t, body, `flyscrape_responses_total{class="4xx"} 1`)
+	require.Contains(t, body, `flyscrape_request_duration_seconds_bucket{host="www.example.com",le="+Inf"} 2`)
+	require.Contains(t, body, `flyscrape_request_duration_seconds_count{host="www.example.com"} 2`)
+	require.Contains(t, body, `flyscrape_script_duration_seconds_count 2`)
+	require.Contains(t, body, "flyscrape_queue_depth 0\n")
+	require.Contains(t, body, "flyscrape_cache_hit_ratio 0\n")
+}
+
+func TestMetricsStatus(t *testing.T) {
+	scraper := runMetrics()
+
+	rec := httptest.NewRecorder()
+	scraper.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var status flyscrape.Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	require.False(t, status.Running)
+	require.Equal(t, 1, status.Progress.Completed)
+	require.Equal(t, 1, status.Progress.Failed)
+	require.Equal(t, int64(2), status.Summary.Pages)
+}
+
+func runMetrics() *flyscrape.Scraper {
+	scraper := flyscrape.NewScraper()
+	scraper.ScrapeFunc = func(p flyscrape.ScrapeParams) (any, error) {
+		return nil, nil
+	}
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		&followlinks.Module{},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					if r.URL.Path == "/" {
+						return flyscrape.MockResponse(200, `<a href="/missing">missing</a>`)
+					}
+					return flyscrape.MockResponse(404, "")
+				})
+			},
+		},
+	}
+	scraper.Run(context.Background())
+	return scraper
+}
+
+func TestMetricsAddrInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	var requested bool
+	scraper := flyscrape.NewScraper()
+	scraper.MetricsAddr = ln.Addr().String()
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					requested = true
+					return flyscrape.MockResponse(200, "")
+				})
+			},
+		},
+	}
+
+	err = scraper.Run(context.Background())
+	require.ErrorContains(t, err, "metrics: ")
+	require.False(t, requested)
+}
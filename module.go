@@ -5,9 +5,11 @@
 package flyscrape
 
 import (
-	"encoding/json"
+	"context"
 	"net/http"
+	"slices"
 	"sync"
+	"time"
 )
 
 type Module interface {
@@ -23,20 +25,49 @@ type TransportAdapter interface {
 	AdaptTransport(http.RoundTripper) http.RoundTripper
 }
 
+// RequestValidator is implemented by modules that decide whether a
+// request is sent. A rejected request comes with the reason, like
+// "domain \"example.com\" is not allowed".
 type RequestValidator interface {
-	ValidateRequest(*Request) bool
+	ValidateRequest(*Request) (ok bool, reason string)
 }
 
 type RequestBuilder interface {
 	BuildRequest(*Request)
 }
 
+// ResponseValidator is implemented by modules that decide whether a
+// response is scraped. It is called before the script extracts the
+// data. Rejected responses are skipped like rejected requests.
+type ResponseValidator interface {
+	ValidateResponse(*Response) (ok bool, reason string)
+}
+
 type ResponseReceiver interface {
 	ReceiveResponse(*Response)
 }
 
+// Scheduler is implemented by modules that limit when requests to a host
+// may be sent. Jobs of hosts that are not ready are held back, so that
+// workers can serve other hosts in the meantime.
+type Scheduler interface {
+	// Schedule tries to reserve a slot for the request. On success, the
+	// returned release function must be called once the request is done.
+	// Otherwise it returns how long to wait before trying again.
+	Schedule(*Request) (release func(), wait time.Duration, ok bool)
+}
+
+// Seeder is implemented by modules that enqueue URLs while the scraper
+// is already running, like URLs that have to be fetched first. Seed is
+// called in its own goroutine and the run does not end before it returns.
+type Seeder interface {
+	Seed(ctx context.Context, v Context)
+}
+
+// Provisioner is implemented by modules that set themselves up before
+// the run. An error aborts the run before any request is sent.
 type Provisioner interface {
-	Provision(Context)
+	Provision(Context) error
 }
 
 type Finalizer interface {
@@ -54,22 +85,28 @@ func RegisterModule(mod Module) {
 	modules[mod.ModuleInfo().ID] = mod
 }
 
-func LoadModules(cfg Config) []Module {
+// LoadModules creates all registered modules from the config. Standard
+// modules that are not registered are left out. Invalid config values
+// are returned as ConfigErrors.
+func LoadModules(cfg Config) ([]Module, error) {
 	modulesMu.RLock()
 	defer modulesMu.RUnlock()
 
 	loaded := map[string]struct{}{}
 	mods := []Module{}
 
+	var errs ConfigErrors
+
 	// load standard modules in order
 	for _, id := range moduleOrder {
 		if _, ok := loaded[id]; ok {
 			continue
 		}
-		mod := modules[id].ModuleInfo().New()
-		if err := json.Unmarshal(cfg, mod); err != nil {
-			panic("failed to decode config: " + err.Error())
+		if _, ok := modules[id]; !ok {
+			continue
 		}
+		mod := modules[id].ModuleInfo().New()
+		errs = appendConfigErrors(errs, decodeConfig(cfg, mod))
 		mods = append(mods, mod)
 		loaded[id] = struct{}{}
 	}
@@ -80,14 +117,26 @@ func LoadModules(cfg Config) []Module {
 			continue
 		}
 		mod := modules[id].ModuleInfo().New()
-		if err := json.Unmarshal(cfg, mod); err != nil {
-			panic("failed to decode config: " + err.Error())
-		}
+		errs = appendConfigErrors(errs, decodeConfig(cfg, mod))
 		mods = append(mods, mod)
 		loaded[id] = struct{}{}
 	}
 
-	return mods
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return mods, nil
+}
+
+// appendConfigErrors appends the errors of keys that are not in errs
+// yet, as some keys are read by multiple modules.
+func appendConfigErrors(errs ConfigErrors, add []*ConfigError) ConfigErrors {
+	for _, err := range add {
+		if !slices.ContainsFunc(errs, func(e *ConfigError) bool { return e.Key == err.Key }) {
+			errs = append(errs, err)
+		}
+	}
+	return errs
 }
 
 var (
@@ -95,11 +144,13 @@ var (
 	modulesMu sync.RWMutex
 
 	moduleOrder = []string{
-		// Transport adapters must be loaded in a specific order.
+		// Transport adapters and schedulers must be loaded in a specific order.
 		// All other modules can be loaded in any order.
+		"dedup", // marks duplicates before they are received by others
 		"proxy",
 		"browser",
 		"retry",
+		"robots",
 		"ratelimit",
 		"cache",
 		"cookies",
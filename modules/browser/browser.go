@@ -7,9 +7,7 @@ package browser
 import (
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"os"
 	"strings"
 	"sync"
 	"time"
@@ -38,9 +36,9 @@ func (Module) ModuleInfo() flyscrape.ModuleInfo {
 	}
 }
 
-func (m *Module) AdaptTransport(t http.RoundTripper) http.RoundTripper {
+func (m *Module) Provision(flyscrape.Context) error {
 	if !m.Browser {
-		return t
+		return nil
 	}
 
 	headless := true
@@ -50,13 +48,18 @@ func (m *Module) AdaptTransport(t http.RoundTripper) http.RoundTripper {
 
 	browser, err := newBrowser(headless)
 	if err != nil {
-		log.Println(err)
-		os.Exit(1)
+		return fmt.Errorf("failed to start browser: %w", err)
 	}
 
 	m.browser = browser
+	return nil
+}
 
-	return chromeTransport(browser)
+func (m *Module) AdaptTransport(t http.RoundTripper) http.RoundTripper {
+	if m.browser == nil {
+		return t
+	}
+	return chromeTransport(m.browser)
 }
 
 func (m *Module) Finalize() {
@@ -188,6 +191,7 @@ func parseCookies(r *http.Request) []*proto.NetworkCookieParam {
 }
 
 var (
+	_ flyscrape.Provisioner      = &Module{}
 	_ flyscrape.TransportAdapter = &Module{}
 	_ flyscrape.Finalizer        = &Module{}
 )
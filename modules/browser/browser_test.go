@@ -5,6 +5,7 @@
 package browser_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -43,7 +44,7 @@ func TestBrowser(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.True(t, called)
 	require.Contains(t, body, "Hello Browser")
@@ -70,7 +71,7 @@ func TestBrowserStatusCode(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Equal(t, 404, statusCode)
 }
@@ -102,7 +103,7 @@ func TestBrowserRequestHeader(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Contains(t, body, "custom-headers")
 }
@@ -129,7 +130,7 @@ func TestBrowserResponseHeader(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Equal(t, header, "bar")
 }
@@ -156,7 +157,7 @@ func TestBrowserUnsetFlyscrapeUserAgent(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	fmt.Println(body)
 	require.Contains(t, body, "Mozilla/5.0")
@@ -6,24 +6,23 @@ package cache
 
 import (
 	"errors"
-	"log"
-	"os"
+	"fmt"
+	"log/slog"
 
 	"go.etcd.io/bbolt"
 )
 
 var cache = []byte("cache")
 
-func NewBoltStore(file string) *BoltStore {
+func NewBoltStore(file string) (*BoltStore, error) {
 	db, err := bbolt.Open(file, 0644, nil)
 	if err != nil {
-		log.Printf("cache: failed to create database file %q: %v\n", file, err)
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to create cache file: %w", err)
 	}
 
 	c := &BoltStore{db: db}
 
-	return c
+	return c, nil
 }
 
 type BoltStore struct {
@@ -65,7 +64,7 @@ func (s *BoltStore) Set(key string, value []byte) {
 		return bucket.Put([]byte(key), value)
 	})
 	if err != nil {
-		log.Printf("cache: failed to insert cache key %q: %v\n", key, err)
+		slog.Error("cache: failed to insert cache key", "key", key, "error", err)
 	}
 }
 
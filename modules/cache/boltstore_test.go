@@ -17,7 +17,8 @@ func TestBoltStore(t *testing.T) {
 	require.NoError(t, err)
 	defer os.RemoveAll(dir)
 
-	store := cache.NewBoltStore(dir + "/test.db")
+	store, err := cache.NewBoltStore(dir + "/test.db")
+	require.NoError(t, err)
 
 	v, ok := store.Get("foo")
 	require.Nil(t, v)
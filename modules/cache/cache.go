@@ -7,6 +7,9 @@ package cache
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"net/http"
 	"net/http/httputil"
 	"path/filepath"
@@ -23,6 +26,7 @@ type Module struct {
 	Cache string `json:"cache"`
 
 	store Store
+	stats *flyscrape.Stats
 }
 
 func (Module) ModuleInfo() flyscrape.ModuleInfo {
@@ -32,15 +36,25 @@ func (Module) ModuleInfo() flyscrape.ModuleInfo {
 	}
 }
 
-func (m *Module) Provision(ctx flyscrape.Context) {
+func (m *Module) Provision(ctx flyscrape.Context) error {
+	m.stats = ctx.Stats()
+
+	var file string
 	switch {
 	case m.Cache == "file":
-		file := replaceExt(ctx.ScriptName(), ".cache")
-		m.store = NewBoltStore(file)
-
+		file = replaceExt(ctx.ScriptName(), ".cache")
 	case strings.HasPrefix(m.Cache, "file:"):
-		m.store = NewBoltStore(strings.TrimPrefix(m.Cache, "file:"))
+		file = strings.TrimPrefix(m.Cache, "file:")
+	default:
+		return nil
+	}
+
+	store, err := NewBoltStore(file)
+	if err != nil {
+		return err
 	}
+	m.store = store
+	return nil
 }
 
 func (m *Module) AdaptTransport(t http.RoundTripper) http.RoundTripper {
@@ -52,9 +66,13 @@ func (m *Module) AdaptTransport(t http.RoundTripper) http.RoundTripper {
 			return t.RoundTrip(r)
 		}
 
-		key := r.Method + " " + r.URL.String()
+		key, err := cacheKey(r)
+		if err != nil {
+			return nil, err
+		}
 		if b, ok := m.store.Get(key); ok {
 			if resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(b)), r); err == nil {
+				m.stats.Add(flyscrape.StatCacheHits, 1)
 				return resp, nil
 			}
 		}
@@ -86,6 +104,27 @@ func (m *Module) Finalize() {
 	}
 }
 
+// cacheKey identifies a request by its method and URL, and by its body
+// if it has one.
+func cacheKey(r *http.Request) (string, error) {
+	key := r.Method + " " + r.URL.String()
+	if r.ContentLength == 0 || r.GetBody == nil {
+		return key, nil
+	}
+
+	body, err := r.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return "", err
+	}
+	return key + " " + hex.EncodeToString(h.Sum(nil)), nil
+}
+
 func nocache(r *http.Request) bool {
 	if r.Header.Get(flyscrape.HeaderBypassCache) != "" {
 		r.Header.Del(flyscrape.HeaderBypassCache)
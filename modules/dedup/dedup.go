@@ -0,0 +1,102 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dedup
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"github.com/philippta/flyscrape"
+)
+
+const defaultDistance = 3
+
+func init() {
+	flyscrape.RegisterModule(Module{})
+}
+
+type Module struct {
+	Dedup         bool `json:"dedup"`
+	DedupDistance *int `json:"dedupDistance"`
+
+	mu     *sync.Mutex
+	hashes map[[sha256.Size]byte]struct{}
+	index  *index
+	stats  *flyscrape.Stats
+}
+
+func (Module) ModuleInfo() flyscrape.ModuleInfo {
+	return flyscrape.ModuleInfo{
+		ID:  "dedup",
+		New: func() flyscrape.Module { return new(Module) },
+	}
+}
+
+func (m *Module) Provision(ctx flyscrape.Context) error {
+	if m.disabled() {
+		return nil
+	}
+
+	m.stats = ctx.Stats()
+
+	m.mu = &sync.Mutex{}
+	m.hashes = map[[sha256.Size]byte]struct{}{}
+
+	distance := defaultDistance
+	if m.DedupDistance != nil {
+		distance = *m.DedupDistance
+	}
+	if distance > 0 {
+		m.index = newIndex(distance)
+	}
+	return nil
+}
+
+// ReceiveResponse marks responses as duplicates whose body was seen
+// before, or whose text is nearly the same as that of a previous page.
+func (m *Module) ReceiveResponse(resp *flyscrape.Response) {
+	if m.disabled() || resp.Error != nil || len(resp.Body) == 0 {
+		return
+	}
+
+	hash := sha256.Sum256(resp.Body)
+
+	var fingerprint uint64
+	var similar bool
+	if m.index != nil {
+		fingerprint, similar = simhash(resp.Body)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.hashes[hash]; ok {
+		m.collapse(resp)
+		return
+	}
+	m.hashes[hash] = struct{}{}
+
+	if similar {
+		if m.index.contains(fingerprint) {
+			m.collapse(resp)
+			return
+		}
+		m.index.add(fingerprint)
+	}
+}
+
+func (m *Module) collapse(resp *flyscrape.Response) {
+	resp.Duplicate = true
+	m.stats.Add(flyscrape.StatDuplicates, 1)
+}
+
+func (m *Module) disabled() bool {
+	return !m.Dedup
+}
+
+var (
+	_ flyscrape.Provisioner      = (*Module)(nil)
+	_ flyscrape.ResponseReceiver = (*Module)(nil)
+)
@@ -0,0 +1,105 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dedup_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/philippta/flyscrape"
+	"github.com/philippta/flyscrape/modules/dedup"
+	"github.com/philippta/flyscrape/modules/followlinks"
+	"github.com/philippta/flyscrape/modules/hook"
+	"github.com/philippta/flyscrape/modules/starturl"
+	"github.com/stretchr/testify/require"
+)
+
+// article is long enough for small changes to make only a small
+// difference in its fingerprint.
+var article = func() string {
+	var sb strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&sb, "<p>Section %d of the article covers topic number %d in detail.</p>", i, i*7)
+	}
+	return sb.String()
+}()
+
+var pages = map[string]string{
+	"/": `
+		<a href="/article">Article</a>
+		<a href="/article?session=1">Article</a>
+		<a href="/print">Print</a>
+		<a href="/other">Other</a>`,
+	"/article":           article + `<a href="/article/more">More</a>`,
+	"/article?session=1": article + `<a href="/article/more">More</a>`,
+	"/print":             `<body>` + article + `<p>Printed on 2024-01-01</p><a href="/print/more">More</a></body>`,
+	"/other":             `<p>Something else entirely, about a different topic, with enough words to count.</p>`,
+}
+
+func TestDedup(t *testing.T) {
+	urls := run(t, &dedup.Module{Dedup: true})
+
+	require.ElementsMatch(t, []string{
+		"http://www.example.com/",
+		"http://www.example.com/article",
+		"http://www.example.com/article/more",
+		"http://www.example.com/other",
+	}, urls)
+}
+
+func TestDedupExact(t *testing.T) {
+	distance := 0
+	urls := run(t, &dedup.Module{Dedup: true, DedupDistance: &distance})
+
+	require.ElementsMatch(t, []string{
+		"http://www.example.com/",
+		"http://www.example.com/article",
+		"http://www.example.com/article/more",
+		"http://www.example.com/print",
+		"http://www.example.com/print/more",
+		"http://www.example.com/other",
+	}, urls)
+}
+
+func TestDedupDisabled(t *testing.T) {
+	urls := run(t, &dedup.Module{})
+	require.Len(t, urls, 7)
+}
+
+func run(t *testing.T, mod *dedup.Module) []string {
+	var urls []string
+	var mu sync.Mutex
+
+	// A single worker, so that the first of the duplicates is kept.
+	scraper := flyscrape.NewScraper()
+	scraper.Options.Workers = 1
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		mod,
+		&followlinks.Module{},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					return flyscrape.MockResponse(200, pages[r.URL.RequestURI()])
+				})
+			},
+			ReceiveResponseFn: func(r *flyscrape.Response) {
+				if r.Duplicate {
+					return
+				}
+				mu.Lock()
+				urls = append(urls, r.Request.URL)
+				mu.Unlock()
+			},
+		},
+	}
+	scraper.Run(context.Background())
+
+	return urls
+}
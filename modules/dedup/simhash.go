@@ -0,0 +1,127 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dedup
+
+import (
+	"bytes"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// shingleSize is the number of consecutive words that are hashed
+	// together, so that the order of the words matters.
+	shingleSize = 2
+
+	// minShingles is the amount of text a page needs for its fingerprint
+	// to be meaningful. Pages with less text are only compared exactly.
+	minShingles = 8
+
+	maxDistance = 31
+)
+
+// simhash computes a 64-bit fingerprint of the visible text of an HTML
+// page. The fingerprints of similar texts differ in only a few bits.
+// It reports false if the page does not contain enough text.
+func simhash(html []byte) (uint64, bool) {
+	words := text(html)
+	if len(words) < shingleSize+minShingles-1 {
+		return 0, false
+	}
+
+	var weights [64]int
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+shingleSize], " ")))
+		sum := h.Sum64()
+
+		for b := 0; b < 64; b++ {
+			if sum&(1<<b) != 0 {
+				weights[b]++
+			} else {
+				weights[b]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for b, w := range weights {
+		if w > 0 {
+			fingerprint |= 1 << b
+		}
+	}
+	return fingerprint, true
+}
+
+// text returns the lowercased words of the visible text of an HTML page.
+func text(html []byte) []string {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return nil
+	}
+	doc.Find("script, style, noscript, template").Remove()
+	return strings.Fields(strings.ToLower(doc.Text()))
+}
+
+// index finds fingerprints within a maximum hamming distance. Fingerprints
+// are split into distance+1 bands. Two fingerprints within the distance
+// have at least one band in common, so only those are compared.
+type index struct {
+	distance int
+	bands    []band
+	buckets  map[uint64][]uint64
+}
+
+type band struct {
+	shift uint
+	mask  uint64
+}
+
+func newIndex(distance int) *index {
+	distance = min(distance, maxDistance)
+
+	n := distance + 1
+	width := 64 / n
+
+	idx := &index{distance: distance, buckets: map[uint64][]uint64{}}
+	for i := 0; i < n; i++ {
+		w := width
+		if i == n-1 {
+			w = 64 - i*width
+		}
+		idx.bands = append(idx.bands, band{
+			shift: uint(i * width),
+			mask:  1<<w - 1,
+		})
+	}
+	return idx
+}
+
+func (idx *index) contains(fingerprint uint64) bool {
+	for i := range idx.bands {
+		for _, other := range idx.buckets[idx.key(i, fingerprint)] {
+			if bits.OnesCount64(fingerprint^other) <= idx.distance {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (idx *index) add(fingerprint uint64) {
+	for i := range idx.bands {
+		key := idx.key(i, fingerprint)
+		idx.buckets[key] = append(idx.buckets[key], fingerprint)
+	}
+}
+
+// key identifies the bucket of a fingerprint for the i-th band.
+func (idx *index) key(i int, fingerprint uint64) uint64 {
+	b := idx.bands[i]
+	return uint64(i)<<58 ^ (fingerprint>>b.shift)&b.mask
+}
@@ -5,6 +5,8 @@
 package depth
 
 import (
+	"fmt"
+
 	"github.com/philippta/flyscrape"
 )
 
@@ -23,8 +25,11 @@ func (Module) ModuleInfo() flyscrape.ModuleInfo {
 	}
 }
 
-func (m *Module) ValidateRequest(r *flyscrape.Request) bool {
-	return r.Depth <= m.Depth
+func (m *Module) ValidateRequest(r *flyscrape.Request) (bool, string) {
+	if r.Depth > m.Depth {
+		return false, fmt.Sprintf("depth %d exceeds the maximum depth of %d", r.Depth, m.Depth)
+	}
+	return true, ""
 }
 
 var _ flyscrape.RequestValidator = (*Module)(nil)
@@ -5,6 +5,7 @@
 package depth_test
 
 import (
+	"context"
 	"net/http"
 	"sync"
 	"testing"
@@ -49,7 +50,7 @@ func TestDepth(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Len(t, urls, 3)
 	require.Contains(t, urls, "http://www.example.com")
@@ -5,6 +5,8 @@
 package domainfilter
 
 import (
+	"fmt"
+
 	"github.com/nlnwa/whatwg-url/url"
 	"github.com/philippta/flyscrape"
 )
@@ -29,7 +31,7 @@ func (Module) ModuleInfo() flyscrape.ModuleInfo {
 	}
 }
 
-func (m *Module) Provision(v flyscrape.Context) {
+func (m *Module) Provision(v flyscrape.Context) error {
 	if m.URL != "" {
 		if u, err := url.Parse(m.URL); err == nil {
 			m.AllowedDomains = append(m.AllowedDomains, u.Host())
@@ -40,36 +42,34 @@ func (m *Module) Provision(v flyscrape.Context) {
 			m.AllowedDomains = append(m.AllowedDomains, u.Host())
 		}
 	}
+	return nil
 }
 
-func (m *Module) ValidateRequest(r *flyscrape.Request) bool {
+func (m *Module) ValidateRequest(r *flyscrape.Request) (bool, string) {
 	if m.disabled() {
-		return true
+		return true, ""
 	}
 
 	u, err := url.Parse(r.URL)
 	if err != nil {
-		return false
+		return false, "invalid url: " + err.Error()
 	}
 
 	host := u.Host()
-	ok := false
 
-	for _, domain := range m.AllowedDomains {
-		if domain == "*" || host == domain {
-			ok = true
-			break
+	for _, domain := range m.BlockedDomains {
+		if host == domain {
+			return false, fmt.Sprintf("domain %q is blocked", host)
 		}
 	}
 
-	for _, domain := range m.BlockedDomains {
-		if host == domain {
-			ok = false
-			break
+	for _, domain := range m.AllowedDomains {
+		if domain == "*" || host == domain {
+			return true, ""
 		}
 	}
 
-	return ok
+	return false, fmt.Sprintf("domain %q is not allowed", host)
 }
 
 func (m *Module) disabled() bool {
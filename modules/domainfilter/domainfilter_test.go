@@ -5,6 +5,7 @@
 package domainfilter_test
 
 import (
+	"context"
 	"net/http"
 	"sync"
 	"testing"
@@ -44,7 +45,7 @@ func TestDomainfilterAllowed(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Len(t, urls, 2)
 	require.Contains(t, urls, "http://www.example.com")
@@ -78,7 +79,7 @@ func TestDomainfilterAllowedAll(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Len(t, urls, 3)
 	require.Contains(t, urls, "http://www.example.com")
@@ -114,7 +115,7 @@ func TestDomainfilterBlocked(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Len(t, urls, 2)
 	require.Contains(t, urls, "http://www.example.com")
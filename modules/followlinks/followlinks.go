@@ -19,6 +19,8 @@ func init() {
 
 type Module struct {
 	Follow *[]string `json:"follow"`
+
+	ctx flyscrape.Context
 }
 
 func (Module) ModuleInfo() flyscrape.ModuleInfo {
@@ -28,10 +30,12 @@ func (Module) ModuleInfo() flyscrape.ModuleInfo {
 	}
 }
 
-func (m *Module) Provision(ctx flyscrape.Context) {
+func (m *Module) Provision(ctx flyscrape.Context) error {
+	m.ctx = ctx
 	if m.Follow == nil {
 		m.Follow = &[]string{"a[href]"}
 	}
+	return nil
 }
 
 func (m *Module) ReceiveResponse(resp *flyscrape.Response) {
@@ -39,26 +43,36 @@ func (m *Module) ReceiveResponse(resp *flyscrape.Response) {
 		return
 	}
 
-	for _, link := range m.parseLinks(string(resp.Body), resp.Request.URL) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(resp.Body)))
+	if err != nil {
+		return
+	}
+
+	originurl, err := url.Parse(resp.Request.URL)
+	if err != nil {
+		return
+	}
+
+	// The page is also known under its canonical URL,
+	// so that one doesn't need to be scraped again.
+	if link, ok := doc.Find(`link[rel="canonical"]`).Attr("href"); ok {
+		canonical, err := originurl.Parse(link)
+		if err == nil && isValidLink(canonical) && canonical.String() != originurl.String() {
+			m.ctx.MarkVisited(canonical.String())
+		}
+	}
+
+	for _, link := range m.parseLinks(doc, originurl) {
 		resp.Visit(link)
 	}
 }
 
-func (m *Module) parseLinks(html string, origin string) []string {
+func (m *Module) parseLinks(doc *goquery.Document, originurl *url.URL) []string {
 	if m.Follow == nil {
 		return nil
 	}
 
 	var links []string
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
-	if err != nil {
-		return nil
-	}
-
-	originurl, err := url.Parse(origin)
-	if err != nil {
-		return nil
-	}
 
 	uniqueLinks := make(map[string]bool)
 
@@ -74,10 +88,11 @@ func (m *Module) parseLinks(html string, origin string) []string {
 			}
 
 			absLink := parsedLink.String()
+			key := m.ctx.Canonicalize(absLink)
 
-			if !uniqueLinks[absLink] {
+			if !uniqueLinks[key] {
 				links = append(links, absLink)
-				uniqueLinks[absLink] = true
+				uniqueLinks[key] = true
 			}
 		})
 	}
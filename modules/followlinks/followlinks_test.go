@@ -5,6 +5,7 @@
 package followlinks_test
 
 import (
+	"context"
 	"net/http"
 	"sync"
 	"testing"
@@ -40,7 +41,7 @@ func TestFollowLinks(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Len(t, urls, 5)
 	require.Contains(t, urls, "http://www.example.com/baz")
@@ -78,7 +79,7 @@ func TestFollowSelector(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Len(t, urls, 2)
 	require.Contains(t, urls, "http://www.example.com/foo/bar")
@@ -111,7 +112,7 @@ func TestFollowDataAttr(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Len(t, urls, 2)
 	require.Contains(t, urls, "http://www.example.com/foo/bar")
@@ -144,7 +145,7 @@ func TestFollowMultiple(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Len(t, urls, 3)
 	require.Contains(t, urls, "http://www.example.com/foo/bar")
@@ -180,8 +181,52 @@ func TestFollowNoFollow(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Len(t, urls, 1)
 	require.Contains(t, urls, "http://www.example.com/foo/bar")
 }
+
+func TestFollowLinksCanonical(t *testing.T) {
+	var urls []string
+	var mu sync.Mutex
+
+	mods := []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		&followlinks.Module{},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					if r.URL.Path == "/" {
+						return flyscrape.MockResponse(200, `
+						<a href="/foo?b=1&a=2">Foo</a>
+						<a href="/foo?a=2&b=1#top">Foo</a>
+						<a href="HTTP://WWW.EXAMPLE.COM:80/foo?a=2&b=1">Foo</a>
+						<a href="/bar">Bar</a>`)
+					}
+					if r.URL.Path == "/bar" {
+						return flyscrape.MockResponse(200, `
+						<link rel="canonical" href="/baz">
+						<a href="/baz">Baz</a>`)
+					}
+					return flyscrape.MockResponse(200, "")
+				})
+			},
+			ReceiveResponseFn: func(r *flyscrape.Response) {
+				mu.Lock()
+				urls = append(urls, r.Request.URL)
+				mu.Unlock()
+			},
+		},
+	}
+
+	scraper := flyscrape.NewScraper()
+	scraper.Modules = mods
+	scraper.Run(context.Background())
+
+	require.ElementsMatch(t, []string{
+		"http://www.example.com/",
+		"http://www.example.com/foo?b=1&a=2",
+		"http://www.example.com/bar",
+	}, urls)
+}
@@ -5,6 +5,7 @@
 package headers_test
 
 import (
+	"context"
 	"net/http"
 	"strings"
 	"testing"
@@ -42,7 +43,7 @@ func TestHeaders(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Equal(t, sentHeaders, gotHeaders)
 }
@@ -64,7 +65,7 @@ func TestHeadersRandomUserAgent(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.NotEmpty(t, userAgent)
 	require.True(t, strings.HasPrefix(userAgent, "Mozilla/5.0 ("))
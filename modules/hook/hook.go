@@ -33,11 +33,11 @@ func (m Module) AdaptTransport(t http.RoundTripper) http.RoundTripper {
 	return m.AdaptTransportFn(t)
 }
 
-func (m Module) ValidateRequest(r *flyscrape.Request) bool {
-	if m.ValidateRequestFn == nil {
-		return true
+func (m Module) ValidateRequest(r *flyscrape.Request) (bool, string) {
+	if m.ValidateRequestFn == nil || m.ValidateRequestFn(r) {
+		return true, ""
 	}
-	return m.ValidateRequestFn(r)
+	return false, "rejected by hook"
 }
 
 func (m Module) BuildRequest(r *flyscrape.Request) {
@@ -54,11 +54,11 @@ func (m Module) ReceiveResponse(r *flyscrape.Response) {
 	m.ReceiveResponseFn(r)
 }
 
-func (m Module) Provision(ctx flyscrape.Context) {
-	if m.ProvisionFn == nil {
-		return
+func (m Module) Provision(ctx flyscrape.Context) error {
+	if m.ProvisionFn != nil {
+		m.ProvisionFn(ctx)
 	}
-	m.ProvisionFn(ctx)
+	return nil
 }
 
 func (m Module) Finalize() {
@@ -9,7 +9,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"sync"
 	"time"
@@ -39,24 +38,24 @@ func (Module) ModuleInfo() flyscrape.ModuleInfo {
 	}
 }
 
-func (m *Module) Provision(ctx flyscrape.Context) {
+func (m *Module) Provision(ctx flyscrape.Context) error {
 	if m.disabled() {
-		return
+		return nil
 	}
 
 	m.mu = &sync.Mutex{}
 
 	if m.Output.File == "" {
 		m.w = nopCloser{os.Stdout}
-		return
+		return nil
 	}
 
 	f, err := os.Create(m.Output.File)
 	if err != nil {
-		log.Printf("failed to create file %q: %v", m.Output.File, err)
-		os.Exit(1)
+		return fmt.Errorf("failed to create output file: %w", err)
 	}
 	m.w = f
+	return nil
 }
 
 func (m *Module) ReceiveResponse(resp *flyscrape.Response) {
@@ -64,7 +63,7 @@ func (m *Module) ReceiveResponse(resp *flyscrape.Response) {
 		return
 	}
 
-	if resp.Error == nil && resp.Data == nil {
+	if resp.Duplicate || (resp.Error == nil && resp.Data == nil) {
 		return
 	}
 
@@ -6,8 +6,8 @@ package ndjson
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
-	"log"
 	"os"
 	"sync"
 	"time"
@@ -36,24 +36,24 @@ func (Module) ModuleInfo() flyscrape.ModuleInfo {
 	}
 }
 
-func (m *Module) Provision(ctx flyscrape.Context) {
+func (m *Module) Provision(ctx flyscrape.Context) error {
 	if m.disabled() {
-		return
+		return nil
 	}
 
 	m.mu = &sync.Mutex{}
 
 	if m.Output.File == "" {
 		m.w = nopCloser{os.Stdout}
-		return
+		return nil
 	}
 
 	f, err := os.Create(m.Output.File)
 	if err != nil {
-		log.Printf("failed to create file %q: %v", m.Output.File, err)
-		os.Exit(1)
+		return fmt.Errorf("failed to create output file: %w", err)
 	}
 	m.w = f
+	return nil
 }
 
 func (m *Module) ReceiveResponse(resp *flyscrape.Response) {
@@ -61,7 +61,7 @@ func (m *Module) ReceiveResponse(resp *flyscrape.Response) {
 		return
 	}
 
-	if resp.Error == nil && resp.Data == nil {
+	if resp.Duplicate || (resp.Error == nil && resp.Data == nil) {
 		return
 	}
 
@@ -31,9 +31,9 @@ func (Module) ModuleInfo() flyscrape.ModuleInfo {
 	}
 }
 
-func (m *Module) Provision(ctx flyscrape.Context) {
+func (m *Module) Provision(ctx flyscrape.Context) error {
 	if m.disabled() {
-		return
+		return nil
 	}
 
 	for _, purl := range append(m.Proxies, m.Proxy) {
@@ -48,6 +48,7 @@ func (m *Module) Provision(ctx flyscrape.Context) {
 		}
 
 	}
+	return nil
 }
 
 func (m *Module) AdaptTransport(t http.RoundTripper) http.RoundTripper {
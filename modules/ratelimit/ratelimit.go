@@ -5,11 +5,15 @@
 package ratelimit
 
 import (
-	"math"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 
 	"github.com/philippta/flyscrape"
+	"golang.org/x/net/publicsuffix"
 )
 
 func init() {
@@ -17,13 +21,58 @@ func init() {
 }
 
 type Module struct {
-	Rate        int  `json:"rate"`
-	Concurrency int  `json:"concurrency"`
-	Browser     bool `json:"browser"`
+	Rate        Limit  `json:"rate"`
+	Concurrency Limit  `json:"concurrency"`
+	Delay       Limit  `json:"delay"`
+	RateLimitBy string `json:"rateLimitBy"`
+	Browser     bool   `json:"browser"`
+
+	mu      *sync.Mutex
+	hosts   map[string]*host
+	browser chan struct{}
+}
+
+// Limit is a per-host setting. It is configured either as a single
+// number that applies to all hosts, or as an object with a default and
+// overrides for individual hosts:
+//
+//	rate: { default: 60, "api.example.com": 600 }
+type Limit struct {
+	Default float64
+	Hosts   map[string]float64
+}
+
+func (l *Limit) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &l.Default); err == nil {
+		return nil
+	}
 
-	ticker      *time.Ticker
-	ratelimit   chan struct{}
-	concurrency chan struct{}
+	var hosts map[string]float64
+	if err := json.Unmarshal(b, &hosts); err != nil {
+		return errors.New("expected number or object of numbers")
+	}
+
+	l.Default = hosts["default"]
+	delete(hosts, "default")
+	l.Hosts = hosts
+	return nil
+}
+
+// For returns the limit of the given host.
+func (l Limit) For(host string) float64 {
+	if v, ok := l.Hosts[host]; ok {
+		return v
+	}
+	return l.Default
+}
+
+func (l Limit) enabled() bool {
+	return l.Default > 0 || len(l.Hosts) > 0
+}
+
+type host struct {
+	next   time.Time
+	active int
 }
 
 func (Module) ModuleInfo() flyscrape.ModuleInfo {
@@ -33,67 +82,142 @@ func (Module) ModuleInfo() flyscrape.ModuleInfo {
 	}
 }
 
-func (m *Module) Provision(v flyscrape.Context) {
-	if m.rateLimitEnabled() {
-		rate := time.Duration(float64(time.Minute) / float64(m.Rate))
-		m.ticker = time.NewTicker(rate)
-		m.ratelimit = make(chan struct{}, int(math.Max(float64(m.Rate)/10, 1)))
+func (m *Module) Provision(v flyscrape.Context) error {
+	m.mu = &sync.Mutex{}
+	m.hosts = map[string]*host{}
 
-		go func() {
-			m.ratelimit <- struct{}{}
-			for range m.ticker.C {
-				m.ratelimit <- struct{}{}
-			}
-		}()
+	// Only a single page is rendered at a time in browser mode,
+	// unless configured otherwise.
+	if m.Browser && !m.Concurrency.enabled() {
+		m.browser = make(chan struct{}, 1)
 	}
+	return nil
+}
 
-	if m.browserEnabled() && !m.concurrencyEnabled() {
-		m.Concurrency = 1
+// Schedule admits a request once the minimum interval since the previous
+// request to the same host has passed and a concurrency slot is free.
+func (m *Module) Schedule(r *flyscrape.Request) (func(), time.Duration, bool) {
+	if m.disabled() {
+		return func() {}, 0, true
 	}
 
-	if m.concurrencyEnabled() {
-		m.concurrency = make(chan struct{}, m.Concurrency)
-		for i := 0; i < m.Concurrency; i++ {
-			m.concurrency <- struct{}{}
-		}
+	key := m.key(r.URL)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h := m.hosts[key]
+	if h == nil {
+		h = &host{}
+		m.hosts[key] = h
 	}
+
+	now := time.Now()
+	if wait := h.next.Sub(now); wait > 0 {
+		return nil, wait, false
+	}
+
+	// A slot becomes free when another request is released, which
+	// retries the waiting jobs. Check again in a while nonetheless.
+	if limit := int(m.Concurrency.For(key)); limit > 0 && h.active >= limit {
+		return nil, 100 * time.Millisecond, false
+	}
+
+	h.active++
+	h.next = now.Add(m.interval(key))
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			h.active--
+			m.mu.Unlock()
+		})
+	}, 0, true
 }
 
 func (m *Module) AdaptTransport(t http.RoundTripper) http.RoundTripper {
 	return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
-		if m.rateLimitEnabled() {
-			<-m.ratelimit
+		if m.browser != nil {
+			select {
+			case m.browser <- struct{}{}:
+			case <-r.Context().Done():
+				return nil, r.Context().Err()
+			}
+			defer func() { <-m.browser }()
 		}
 
-		if m.concurrencyEnabled() {
-			<-m.concurrency
-			defer func() { m.concurrency <- struct{}{} }()
+		// Requests outside of the job queue, like nested scrapes, wait
+		// for their host to become ready.
+		if !m.disabled() && !flyscrape.IsScheduled(r) {
+			release, err := m.wait(r)
+			if err != nil {
+				return nil, err
+			}
+			defer release()
 		}
 
 		return t.RoundTrip(r)
 	})
 }
 
-func (m *Module) Finalize() {
-	if m.rateLimitEnabled() {
-		m.ticker.Stop()
+func (m *Module) wait(r *http.Request) (func(), error) {
+	req := &flyscrape.Request{Method: r.Method, URL: r.URL.String()}
+
+	for {
+		release, wait, ok := m.Schedule(req)
+		if ok {
+			return release, nil
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			t.Stop()
+			return nil, r.Context().Err()
+		}
 	}
 }
 
-func (m *Module) rateLimitEnabled() bool {
-	return m.Rate != 0
+// interval returns the minimum time between two requests to a host.
+func (m *Module) interval(key string) time.Duration {
+	var interval time.Duration
+	if rate := m.Rate.For(key); rate > 0 {
+		interval = time.Duration(float64(time.Minute) / rate)
+	}
+	if delay := time.Duration(m.Delay.For(key) * float64(time.Millisecond)); delay > interval {
+		interval = delay
+	}
+	return interval
 }
 
-func (m *Module) concurrencyEnabled() bool {
-	return m.Concurrency > 0
+// key returns the host, or the registered domain, the limits of a URL
+// are tracked by.
+func (m *Module) key(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+
+	host := u.Hostname()
+	if m.RateLimitBy != "domain" {
+		return host
+	}
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
 }
 
-func (m *Module) browserEnabled() bool {
-	return m.Browser
+func (m *Module) disabled() bool {
+	return !m.Rate.enabled() && !m.Concurrency.enabled() && !m.Delay.enabled()
 }
 
 var (
 	_ flyscrape.TransportAdapter = (*Module)(nil)
 	_ flyscrape.Provisioner      = (*Module)(nil)
-	_ flyscrape.Finalizer        = (*Module)(nil)
+	_ flyscrape.Scheduler        = (*Module)(nil)
 )
@@ -5,6 +5,8 @@
 package ratelimit_test
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"sync"
 	"testing"
@@ -36,14 +38,14 @@ func TestRatelimit(t *testing.T) {
 			},
 		},
 		&ratelimit.Module{
-			Rate: 240,
+			Rate: ratelimit.Limit{Default: 240},
 		},
 	}
 
 	start := time.Now()
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	first := times[0].Add(-250 * time.Millisecond)
 	second := times[1].Add(-500 * time.Millisecond)
@@ -80,15 +82,135 @@ func TestRatelimitConcurrency(t *testing.T) {
 			},
 		},
 		&ratelimit.Module{
-			Concurrency: 2,
+			Concurrency: ratelimit.Limit{Default: 2},
 		},
 	}
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Len(t, times, 5)
 	require.Less(t, times[2].Sub(times[1]), time.Millisecond)
 	require.Less(t, times[4].Sub(times[3]), time.Millisecond)
 }
+
+func TestRatelimitPerHost(t *testing.T) {
+	var mu sync.Mutex
+	times := map[string]time.Time{}
+
+	mods := []flyscrape.Module{
+		&starturl.Module{URLs: []string{
+			"http://a.example.com/1",
+			"http://a.example.com/2",
+			"http://b.example.com/1",
+			"http://b.example.com/2",
+			"http://b.example.com/3",
+		}},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					mu.Lock()
+					times[r.URL.String()] = time.Now()
+					mu.Unlock()
+					return flyscrape.MockResponse(200, "")
+				})
+			},
+		},
+		&ratelimit.Module{
+			Rate: ratelimit.Limit{Hosts: map[string]float64{"a.example.com": 120}},
+		},
+	}
+
+	start := time.Now()
+	scraper := flyscrape.NewScraper()
+	scraper.Options.Workers = 1
+	scraper.Modules = mods
+	scraper.Run(context.Background())
+
+	require.Len(t, times, 5)
+
+	// The slow host must not hold up the other one.
+	require.Less(t, times["http://b.example.com/3"].Sub(start), 250*time.Millisecond)
+	require.Greater(t, times["http://a.example.com/2"].Sub(start), 450*time.Millisecond)
+}
+
+func TestRatelimitDomain(t *testing.T) {
+	var times []time.Time
+	var mu sync.Mutex
+
+	mods := []flyscrape.Module{
+		&starturl.Module{URLs: []string{
+			"http://a.example.com/",
+			"http://b.example.com/",
+		}},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					mu.Lock()
+					times = append(times, time.Now())
+					mu.Unlock()
+					return flyscrape.MockResponse(200, "")
+				})
+			},
+		},
+		&ratelimit.Module{
+			Delay:       ratelimit.Limit{Default: 300},
+			RateLimitBy: "domain",
+		},
+	}
+
+	scraper := flyscrape.NewScraper()
+	scraper.Modules = mods
+	scraper.Run(context.Background())
+
+	require.Len(t, times, 2)
+	require.Greater(t, times[1].Sub(times[0]), 250*time.Millisecond)
+}
+
+func TestRatelimitLimitJSON(t *testing.T) {
+	var m ratelimit.Module
+	err := json.Unmarshal([]byte(`{"rate": 60, "concurrency": {"default": 2, "api.example.com": 10}}`), &m)
+	require.NoError(t, err)
+
+	require.Equal(t, float64(60), m.Rate.For("example.com"))
+	require.Equal(t, float64(2), m.Concurrency.For("example.com"))
+	require.Equal(t, float64(10), m.Concurrency.For("api.example.com"))
+
+	err = json.Unmarshal([]byte(`{"rate": "60"}`), &m)
+	require.Error(t, err)
+}
+
+func TestRatelimitConcurrencyNested(t *testing.T) {
+	var urls []string
+	var mu sync.Mutex
+
+	mods := []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					mu.Lock()
+					urls = append(urls, r.URL.String())
+					mu.Unlock()
+					return flyscrape.MockResponse(200, "")
+				})
+			},
+		},
+		&ratelimit.Module{
+			Concurrency: ratelimit.Limit{Default: 1},
+		},
+	}
+
+	scraper := flyscrape.NewScraper()
+	scraper.Modules = mods
+	scraper.ScrapeFunc = func(p flyscrape.ScrapeParams) (any, error) {
+		return p.Process(context.Background(), "http://www.example.com/nested")
+	}
+	scraper.Run(context.Background())
+
+	require.Equal(t, []string{
+		"http://www.example.com/",
+		"http://www.example.com/nested",
+	}, urls)
+}
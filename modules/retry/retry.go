@@ -5,6 +5,7 @@
 package retry
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net"
@@ -25,6 +26,8 @@ type Module struct {
 	semaphore chan struct{}
 
 	RetryDelays []time.Duration
+
+	stats *flyscrape.Stats
 }
 
 func (Module) ModuleInfo() flyscrape.ModuleInfo {
@@ -34,10 +37,12 @@ func (Module) ModuleInfo() flyscrape.ModuleInfo {
 	}
 }
 
-func (m *Module) Provision(flyscrape.Context) {
+func (m *Module) Provision(ctx flyscrape.Context) error {
+	m.stats = ctx.Stats()
 	if m.RetryDelays == nil {
 		m.RetryDelays = defaultRetryDelays
 	}
+	return nil
 }
 
 func (m *Module) AdaptTransport(t http.RoundTripper) http.RoundTripper {
@@ -50,8 +55,15 @@ func (m *Module) AdaptTransport(t http.RoundTripper) http.RoundTripper {
 		for _, delay := range m.RetryDelays {
 			drainBody(resp, err)
 
-			time.Sleep(retryAfter(resp, delay))
+			if err := sleep(r.Context(), retryAfter(resp, delay)); err != nil {
+				return nil, err
+			}
+
+			if r, err = rewind(r); err != nil {
+				return nil, err
+			}
 
+			m.stats.Add(flyscrape.StatRetries, 1)
 			resp, err = t.RoundTrip(r)
 			if !shouldRetry(resp, err) {
 				break
@@ -62,6 +74,23 @@ func (m *Module) AdaptTransport(t http.RoundTripper) http.RoundTripper {
 	})
 }
 
+// rewind returns a copy of the request with a fresh body, as the body
+// has been consumed by the previous attempt.
+func rewind(r *http.Request) (*http.Request, error) {
+	if r.Body == nil || r.GetBody == nil {
+		return r, nil
+	}
+
+	body, err := r.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	r = r.Clone(r.Context())
+	r.Body = body
+	return r, nil
+}
+
 func shouldRetry(resp *http.Response, err error) bool {
 	statusCodes := []int{
 		http.StatusForbidden,
@@ -92,6 +121,18 @@ func shouldRetry(resp *http.Response, err error) bool {
 	return false
 }
 
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func drainBody(resp *http.Response, err error) {
 	if err == nil && resp != nil && resp.Body != nil {
 		io.Copy(io.Discard, resp.Body)
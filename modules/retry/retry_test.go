@@ -5,10 +5,12 @@
 package retry_test
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -45,7 +47,7 @@ func TestRetry(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Equal(t, 3, count)
 }
@@ -88,7 +90,7 @@ func TestRetryStatusCodes(t *testing.T) {
 
 			scraper := flyscrape.NewScraper()
 			scraper.Modules = mods
-			scraper.Run()
+			scraper.Run(context.Background())
 
 			if test.retry {
 				require.NotEqual(t, 1, count)
@@ -133,9 +135,28 @@ func TestRetryErrors(t *testing.T) {
 
 			scraper := flyscrape.NewScraper()
 			scraper.Modules = mods
-			scraper.Run()
+			scraper.Run(context.Background())
 
 			require.NotEqual(t, 1, count)
 		})
 	}
 }
+
+func TestRetryBody(t *testing.T) {
+	t.Parallel()
+	var bodies []string
+
+	mod := &retry.Module{RetryDelays: []time.Duration{10 * time.Millisecond}}
+	rt := mod.AdaptTransport(flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		return flyscrape.MockResponse(http.StatusServiceUnavailable, "service unavailable")
+	}))
+
+	req, err := http.NewRequest(http.MethodPost, "http://www.example.com", strings.NewReader("q=1"))
+	require.NoError(t, err)
+
+	_, err = rt.RoundTrip(req)
+	require.NoError(t, err)
+	require.Equal(t, []string{"q=1", "q=1"}, bodies)
+}
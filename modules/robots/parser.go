@@ -0,0 +1,179 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package robots
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// File is a parsed robots.txt file.
+type File struct {
+	Groups   []*Group
+	Sitemaps []string
+}
+
+// Group holds the rules for a set of user-agents.
+type Group struct {
+	Agents     []string
+	Rules      []Rule
+	CrawlDelay time.Duration
+}
+
+// Rule allows or disallows all paths matching its pattern.
+type Rule struct {
+	Allow   bool
+	Pattern string
+
+	re *regexp.Regexp
+}
+
+// Parse parses a robots.txt file. Lines that cannot be parsed are skipped.
+func Parse(r io.Reader) *File {
+	f := &File{}
+
+	var group *Group
+	var rules bool
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Consecutive user-agent lines share the group that follows.
+			if group == nil || rules {
+				group = &Group{}
+				f.Groups = append(f.Groups, group)
+				rules = false
+			}
+			group.Agents = append(group.Agents, strings.ToLower(value))
+
+		case "allow", "disallow":
+			if group == nil {
+				continue
+			}
+			rules = true
+
+			// An empty disallow rule allows everything.
+			if value == "" {
+				continue
+			}
+			group.Rules = append(group.Rules, newRule(key == "allow", value))
+
+		case "crawl-delay":
+			if group == nil {
+				continue
+			}
+			rules = true
+
+			if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+				group.CrawlDelay = time.Duration(secs * float64(time.Second))
+			}
+
+		case "sitemap":
+			if value != "" {
+				f.Sitemaps = append(f.Sitemaps, value)
+			}
+		}
+	}
+
+	return f
+}
+
+// Group returns the rules that apply to the given user-agent token. All
+// groups naming the token are merged. If there are none, the groups for
+// "*" are used instead. Group returns an empty group if neither exists.
+func (f *File) Group(agent string) *Group {
+	agent = strings.ToLower(agent)
+
+	if g := f.merge(agent); g != nil {
+		return g
+	}
+	if g := f.merge("*"); g != nil {
+		return g
+	}
+	return &Group{}
+}
+
+func (f *File) merge(agent string) *Group {
+	var merged *Group
+	for _, g := range f.Groups {
+		for _, a := range g.Agents {
+			if a != agent {
+				continue
+			}
+			if merged == nil {
+				merged = &Group{}
+			}
+			merged.Agents = append(merged.Agents, a)
+			merged.Rules = append(merged.Rules, g.Rules...)
+			merged.CrawlDelay = max(merged.CrawlDelay, g.CrawlDelay)
+			break
+		}
+	}
+	return merged
+}
+
+// Allowed reports whether the path, including the query, may be
+// crawled. The longest matching rule wins. If an allow and a disallow
+// rule are equally long, the allow rule wins.
+func (g *Group) Allowed(path string) bool {
+	r := g.Match(path)
+	return r == nil || r.Allow
+}
+
+// Match returns the rule that decides whether the path may be crawled,
+// or nil if no rule matches.
+func (g *Group) Match(path string) *Rule {
+	if path == "/robots.txt" {
+		return nil
+	}
+
+	var match *Rule
+	for i, r := range g.Rules {
+		if !r.re.MatchString(path) {
+			continue
+		}
+		if match == nil || len(r.Pattern) > len(match.Pattern) ||
+			(len(r.Pattern) == len(match.Pattern) && r.Allow) {
+			match = &g.Rules[i]
+		}
+	}
+	return match
+}
+
+func newRule(allow bool, pattern string) Rule {
+	// "*" matches any sequence of characters and a trailing "$"
+	// anchors the pattern at the end of the path.
+	expr := pattern
+	end := strings.HasSuffix(expr, "$")
+	if end {
+		expr = strings.TrimSuffix(expr, "$")
+	}
+
+	expr = regexp.QuoteMeta(expr)
+	expr = strings.ReplaceAll(expr, `\*`, `.*`)
+	expr = "^" + expr
+	if end {
+		expr += "$"
+	}
+
+	return Rule{Allow: allow, Pattern: pattern, re: regexp.MustCompile(expr)}
+}
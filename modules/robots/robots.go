@@ -0,0 +1,217 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package robots
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/philippta/flyscrape"
+)
+
+const (
+	defaultUserAgent = "flyscrape"
+
+	// Only the first 500 KiB of a robots.txt file are parsed.
+	maxFileSize = 500 << 10
+)
+
+func init() {
+	flyscrape.RegisterModule(Module{})
+}
+
+type Module struct {
+	Robots          bool   `json:"robots"`
+	RobotsUserAgent string `json:"robotsUserAgent"`
+
+	ctx    context.Context
+	client *http.Client
+	mu     *sync.Mutex
+	hosts  map[string]*host
+}
+
+type host struct {
+	url      string
+	once     sync.Once
+	group    *Group
+	sitemaps []string
+	next     time.Time
+}
+
+func (Module) ModuleInfo() flyscrape.ModuleInfo {
+	return flyscrape.ModuleInfo{
+		ID:  "robots",
+		New: func() flyscrape.Module { return new(Module) },
+	}
+}
+
+func (m *Module) Provision(ctx flyscrape.Context) error {
+	m.ctx = ctx.Context()
+	m.client = ctx.HTTPClient()
+	m.mu = &sync.Mutex{}
+	m.hosts = map[string]*host{}
+
+	if m.RobotsUserAgent == "" {
+		m.RobotsUserAgent = defaultUserAgent
+	}
+	return nil
+}
+
+func (m *Module) ValidateRequest(r *flyscrape.Request) (bool, string) {
+	if m.disabled() {
+		return true, ""
+	}
+
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return false, "invalid url: " + err.Error()
+	}
+
+	if rule := m.host(u).group.Match(path(u)); rule != nil && !rule.Allow {
+		return false, fmt.Sprintf("disallowed by robots.txt rule %q", rule.Pattern)
+	}
+	return true, ""
+}
+
+// Schedule holds back requests until the Crawl-delay of their host has
+// passed since the previous request.
+func (m *Module) Schedule(r *flyscrape.Request) (func(), time.Duration, bool) {
+	if m.disabled() {
+		return func() {}, 0, true
+	}
+
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return func() {}, 0, true
+	}
+
+	h := m.host(u)
+
+	// Disallowed requests are rejected by ValidateRequest right away.
+	if h.group.CrawlDelay == 0 || !h.group.Allowed(path(u)) {
+		return func() {}, 0, true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if wait := h.next.Sub(now); wait > 0 {
+		return nil, wait, false
+	}
+	h.next = now.Add(h.group.CrawlDelay)
+
+	return func() {}, 0, true
+}
+
+// Sitemaps returns the sitemap URLs listed in all robots.txt files
+// fetched so far.
+func (m *Module) Sitemaps() []string {
+	if m.disabled() {
+		return nil
+	}
+
+	m.mu.Lock()
+	hosts := make([]*host, 0, len(m.hosts))
+	for _, h := range m.hosts {
+		hosts = append(hosts, h)
+	}
+	m.mu.Unlock()
+
+	var sitemaps []string
+	for _, h := range hosts {
+		m.load(h)
+		sitemaps = append(sitemaps, h.sitemaps...)
+	}
+	return sitemaps
+}
+
+// host returns the robots.txt rules of the URL's host, fetching them
+// on first use.
+func (m *Module) host(u *url.URL) *host {
+	key := u.Scheme + "://" + u.Host
+
+	m.mu.Lock()
+	h := m.hosts[key]
+	if h == nil {
+		h = &host{url: key + "/robots.txt"}
+		m.hosts[key] = h
+	}
+	m.mu.Unlock()
+
+	m.load(h)
+	return h
+}
+
+func (m *Module) load(h *host) {
+	h.once.Do(func() {
+		f := m.fetch(h.url)
+		h.group = f.Group(m.RobotsUserAgent)
+		h.sitemaps = f.Sitemaps
+	})
+}
+
+// fetch downloads and parses a robots.txt file. A missing file allows
+// everything, while an unreachable one disallows everything.
+func (m *Module) fetch(rawurl string) *File {
+	disallowAll := &File{Groups: []*Group{{
+		Agents: []string{"*"},
+		Rules:  []Rule{newRule(false, "/")},
+	}}}
+
+	req, err := http.NewRequestWithContext(m.ctx, http.MethodGet, rawurl, nil)
+	if err != nil {
+		slog.Warn("robots: failed to fetch robots.txt", "url", rawurl, "error", err)
+		return disallowAll
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		if m.ctx.Err() == nil {
+			slog.Warn("robots: failed to fetch robots.txt", "url", rawurl, "error", err)
+		}
+		return disallowAll
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		slog.Warn("robots: failed to fetch robots.txt", "url", rawurl, "status", resp.StatusCode)
+		return disallowAll
+	case resp.StatusCode >= 400:
+		return &File{}
+	}
+
+	return Parse(io.LimitReader(resp.Body, maxFileSize))
+}
+
+func (m *Module) disabled() bool {
+	return !m.Robots
+}
+
+// path returns the path and query of a URL, which is what rules
+// are matched against.
+func path(u *url.URL) string {
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	if u.RawQuery != "" {
+		p += "?" + u.RawQuery
+	}
+	return p
+}
+
+var (
+	_ flyscrape.RequestValidator = (*Module)(nil)
+	_ flyscrape.Provisioner      = (*Module)(nil)
+	_ flyscrape.Scheduler        = (*Module)(nil)
+)
@@ -0,0 +1,208 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package robots_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/philippta/flyscrape"
+	"github.com/philippta/flyscrape/modules/followlinks"
+	"github.com/philippta/flyscrape/modules/hook"
+	"github.com/philippta/flyscrape/modules/robots"
+	"github.com/philippta/flyscrape/modules/starturl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRobotsDisallow(t *testing.T) {
+	urls := run(t, &robots.Module{Robots: true}, 200, `
+	User-agent: *
+	Disallow: /private
+	Allow: /private/public
+	`)
+
+	require.ElementsMatch(t, []string{
+		"http://www.example.com/robots.txt",
+		"http://www.example.com/",
+		"http://www.example.com/foo",
+		"http://www.example.com/private/public",
+	}, urls)
+}
+
+func TestRobotsUserAgent(t *testing.T) {
+	urls := run(t, &robots.Module{Robots: true, RobotsUserAgent: "MyBot"}, 200, `
+	User-agent: *
+	Disallow: /
+
+	User-agent: mybot
+	Disallow: /foo
+	`)
+
+	require.ElementsMatch(t, []string{
+		"http://www.example.com/robots.txt",
+		"http://www.example.com/",
+		"http://www.example.com/private",
+		"http://www.example.com/private/public",
+	}, urls)
+}
+
+func TestRobotsNotFound(t *testing.T) {
+	urls := run(t, &robots.Module{Robots: true}, 404, "")
+	require.Len(t, urls, 5)
+}
+
+func TestRobotsServerError(t *testing.T) {
+	urls := run(t, &robots.Module{Robots: true}, 500, "")
+	require.Equal(t, []string{"http://www.example.com/robots.txt"}, urls)
+}
+
+func TestRobotsDisabled(t *testing.T) {
+	urls := run(t, &robots.Module{}, 200, `
+	User-agent: *
+	Disallow: /
+	`)
+	require.Len(t, urls, 4)
+	require.NotContains(t, urls, "http://www.example.com/robots.txt")
+}
+
+func TestRobotsCrawlDelay(t *testing.T) {
+	mod := &robots.Module{Robots: true}
+
+	start := time.Now()
+	run(t, mod, 200, `
+	User-agent: *
+	Crawl-delay: 0.05
+	`)
+
+	// Three pages after the first one, each 50ms apart.
+	require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+}
+
+func TestRobotsSitemaps(t *testing.T) {
+	mod := &robots.Module{Robots: true}
+	run(t, mod, 200, `
+	Sitemap: http://www.example.com/sitemap.xml
+	User-agent: *
+	Disallow:
+	`)
+
+	require.Equal(t, []string{"http://www.example.com/sitemap.xml"}, mod.Sitemaps())
+}
+
+func TestRobotsCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	scraper := flyscrape.NewScraper()
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		&robots.Module{Robots: true},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					// The robots.txt host stalls until the request is canceled.
+					cancel()
+					<-r.Context().Done()
+					return nil, r.Context().Err()
+				})
+			},
+		},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		scraper.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not stop")
+	}
+}
+
+func TestRobotsParse(t *testing.T) {
+	f := robots.Parse(strings.NewReader(`
+	# comment
+	User-agent: a
+	User-agent: b
+	Disallow: /*.pdf$   # no pdfs
+	Crawl-delay: 2
+
+	User-agent: c
+	Allow: /
+
+	Sitemap: http://www.example.com/sitemap.xml
+	`))
+
+	require.Len(t, f.Groups, 2)
+	require.Equal(t, []string{"http://www.example.com/sitemap.xml"}, f.Sitemaps)
+
+	g := f.Group("B")
+	require.Equal(t, 2*time.Second, g.CrawlDelay)
+	require.False(t, g.Allowed("/file.pdf"))
+	require.False(t, g.Allowed("/dir/file.pdf"))
+	require.True(t, g.Allowed("/file.pdf?download=1"))
+	require.True(t, g.Allowed("/file.html"))
+
+	// Unknown agents without a "*" group are allowed everything.
+	require.True(t, f.Group("d").Allowed("/file.pdf"))
+}
+
+func TestRobotsLongestMatch(t *testing.T) {
+	g := robots.Parse(strings.NewReader(`
+	User-agent: *
+	Disallow: /page
+	Allow: /page
+	Disallow: /a/*/c
+	Allow: /a/b
+	`)).Group("flyscrape")
+
+	require.True(t, g.Allowed("/page"))
+	require.False(t, g.Allowed("/a/b/c"))
+	require.True(t, g.Allowed("/a/b/d"))
+	require.True(t, g.Allowed("/robots.txt"))
+}
+
+func run(t *testing.T, mod *robots.Module, status int, robotstxt string) []string {
+	var urls []string
+	var mu sync.Mutex
+
+	robotstxt = strings.ReplaceAll(robotstxt, "\t", "")
+
+	scraper := flyscrape.NewScraper()
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		&followlinks.Module{},
+		mod,
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					mu.Lock()
+					urls = append(urls, r.URL.String())
+					mu.Unlock()
+
+					switch r.URL.Path {
+					case "/robots.txt":
+						return flyscrape.MockResponse(status, robotstxt)
+					case "/":
+						return flyscrape.MockResponse(200, `
+						<a href="/foo">Foo</a>
+						<a href="/private">Private</a>
+						<a href="/private/public">Public</a>`)
+					}
+					return flyscrape.MockResponse(200, "")
+				})
+			},
+		},
+	}
+	scraper.Run(context.Background())
+
+	return urls
+}
@@ -0,0 +1,223 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sitemap
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/philippta/flyscrape"
+)
+
+func init() {
+	flyscrape.RegisterModule(Module{})
+}
+
+type Module struct {
+	Sitemaps     []string `json:"sitemaps"`
+	SitemapURLs  []string `json:"sitemapURLs"`
+	SitemapSince string   `json:"sitemapSince"`
+
+	client *http.Client
+	urlsRE []*regexp.Regexp
+	since  time.Time
+}
+
+func (Module) ModuleInfo() flyscrape.ModuleInfo {
+	return flyscrape.ModuleInfo{
+		ID:  "sitemap",
+		New: func() flyscrape.Module { return new(Module) },
+	}
+}
+
+func (m *Module) Provision(ctx flyscrape.Context) error {
+	if m.disabled() {
+		return nil
+	}
+
+	m.client = ctx.HTTPClient()
+
+	for _, pat := range m.SitemapURLs {
+		re, err := regexp.Compile(pat)
+		if err != nil {
+			return fmt.Errorf("invalid url pattern %q: %w", pat, err)
+		}
+		m.urlsRE = append(m.urlsRE, re)
+	}
+
+	if m.SitemapSince != "" {
+		since, ok := parseDate(m.SitemapSince)
+		if !ok {
+			return fmt.Errorf("invalid date %q", m.SitemapSince)
+		}
+		m.since = since
+	}
+	return nil
+}
+
+// Seed fetches all sitemaps, following sitemap indexes, and visits the
+// URLs they list.
+func (m *Module) Seed(ctx context.Context, v flyscrape.Context) {
+	if m.disabled() {
+		return
+	}
+
+	seen := map[string]bool{}
+	queue := append([]string(nil), m.Sitemaps...)
+
+	for len(queue) > 0 && ctx.Err() == nil {
+		sitemap := queue[0]
+		queue = queue[1:]
+
+		if seen[sitemap] {
+			continue
+		}
+		seen[sitemap] = true
+
+		err := m.fetch(ctx, sitemap, func(e entry, index bool) {
+			if !m.modified(e) {
+				return
+			}
+			if index {
+				queue = append(queue, e.Loc)
+				return
+			}
+			if m.matches(e.Loc) {
+				v.Visit(e.Loc)
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("sitemap: failed to read sitemap", "url", sitemap, "error", err)
+		}
+	}
+}
+
+// entry is a <url> of a sitemap or a <sitemap> of a sitemap index.
+type entry struct {
+	Loc     string `xml:"loc"`
+	Lastmod string `xml:"lastmod"`
+}
+
+// fetch reads a sitemap or sitemap index and calls fn for each entry.
+// The file is decoded as it is downloaded, so that large sitemaps don't
+// have to be held in memory.
+func (m *Module) fetch(ctx context.Context, url string, fn func(e entry, index bool)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	var r io.Reader = bufio.NewReader(resp.Body)
+
+	// Compressed sitemaps are usually served as files rather than with
+	// a Content-Encoding, so check for the gzip magic number instead.
+	if magic, _ := r.(*bufio.Reader).Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || (start.Name.Local != "url" && start.Name.Local != "sitemap") {
+			continue
+		}
+
+		var e entry
+		if err := dec.DecodeElement(&e, &start); err != nil {
+			return err
+		}
+		e.Loc = strings.TrimSpace(e.Loc)
+		e.Lastmod = strings.TrimSpace(e.Lastmod)
+		if e.Loc == "" {
+			continue
+		}
+
+		fn(e, start.Name.Local == "sitemap")
+	}
+}
+
+// modified reports whether the entry was modified since the configured
+// date. Entries without a valid lastmod are always included.
+func (m *Module) modified(e entry) bool {
+	if m.since.IsZero() || e.Lastmod == "" {
+		return true
+	}
+
+	lastmod, ok := parseDate(e.Lastmod)
+	if !ok {
+		return true
+	}
+	return !lastmod.Before(m.since)
+}
+
+func (m *Module) matches(url string) bool {
+	if len(m.urlsRE) == 0 {
+		return true
+	}
+	for _, re := range m.urlsRE {
+		if re.MatchString(url) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Module) disabled() bool {
+	return len(m.Sitemaps) == 0
+}
+
+// parseDate parses the W3C datetime formats used by sitemaps.
+func parseDate(s string) (time.Time, bool) {
+	layouts := []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04Z07:00",
+		time.DateOnly,
+		"2006-01",
+		"2006",
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+var (
+	_ flyscrape.Provisioner = (*Module)(nil)
+	_ flyscrape.Seeder      = (*Module)(nil)
+)
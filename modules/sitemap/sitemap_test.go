@@ -0,0 +1,144 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sitemap_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/philippta/flyscrape"
+	"github.com/philippta/flyscrape/modules/hook"
+	"github.com/philippta/flyscrape/modules/sitemap"
+	"github.com/stretchr/testify/require"
+)
+
+const index = `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap>
+		<loc>http://www.example.com/sitemap-products.xml.gz</loc>
+		<lastmod>2024-03-01</lastmod>
+	</sitemap>
+	<sitemap>
+		<loc>http://www.example.com/sitemap-pages.xml</loc>
+		<lastmod>2023-01-01T10:00:00+00:00</lastmod>
+	</sitemap>
+</sitemapindex>`
+
+const products = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url>
+		<loc>http://www.example.com/products/1</loc>
+		<lastmod>2024-02-01</lastmod>
+	</url>
+	<url>
+		<loc>http://www.example.com/products/2</loc>
+		<lastmod>2023-06-01</lastmod>
+	</url>
+	<url>
+		<loc> http://www.example.com/products/3 </loc>
+	</url>
+</urlset>`
+
+const pages = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>http://www.example.com/about</loc></url>
+</urlset>`
+
+func TestSitemap(t *testing.T) {
+	urls := run(t, &sitemap.Module{
+		Sitemaps: []string{"http://www.example.com/sitemap.xml"},
+	})
+
+	require.ElementsMatch(t, []string{
+		"http://www.example.com/products/1",
+		"http://www.example.com/products/2",
+		"http://www.example.com/products/3",
+		"http://www.example.com/about",
+	}, urls)
+}
+
+func TestSitemapSince(t *testing.T) {
+	urls := run(t, &sitemap.Module{
+		Sitemaps:     []string{"http://www.example.com/sitemap.xml"},
+		SitemapSince: "2024-01-01",
+	})
+
+	require.ElementsMatch(t, []string{
+		"http://www.example.com/products/1",
+		"http://www.example.com/products/3",
+	}, urls)
+}
+
+func TestSitemapURLs(t *testing.T) {
+	urls := run(t, &sitemap.Module{
+		Sitemaps:    []string{"http://www.example.com/sitemap.xml"},
+		SitemapURLs: []string{`/products/[12]$`},
+	})
+
+	require.ElementsMatch(t, []string{
+		"http://www.example.com/products/1",
+		"http://www.example.com/products/2",
+	}, urls)
+}
+
+func TestSitemapNotFound(t *testing.T) {
+	urls := run(t, &sitemap.Module{
+		Sitemaps: []string{
+			"http://www.example.com/missing.xml",
+			"http://www.example.com/sitemap-pages.xml",
+		},
+	})
+
+	require.Equal(t, []string{"http://www.example.com/about"}, urls)
+}
+
+func run(t *testing.T, mod *sitemap.Module) []string {
+	var urls []string
+	var mu sync.Mutex
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(products))
+	w.Close()
+
+	scraper := flyscrape.NewScraper()
+	scraper.Modules = []flyscrape.Module{
+		mod,
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					switch r.URL.Path {
+					case "/sitemap.xml":
+						return flyscrape.MockResponse(200, index)
+					case "/sitemap-pages.xml":
+						return flyscrape.MockResponse(200, pages)
+					case "/sitemap-products.xml.gz":
+						return &http.Response{
+							StatusCode: 200,
+							Status:     "200 OK",
+							Body:       io.NopCloser(bytes.NewReader(gz.Bytes())),
+						}, nil
+					case "/missing.xml":
+						return flyscrape.MockResponse(404, "")
+					}
+					return flyscrape.MockResponse(200, "")
+				})
+			},
+			ReceiveResponseFn: func(r *flyscrape.Response) {
+				mu.Lock()
+				urls = append(urls, r.Request.URL)
+				mu.Unlock()
+			},
+		},
+	}
+	scraper.Run(context.Background())
+
+	return urls
+}
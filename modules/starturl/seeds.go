@@ -0,0 +1,141 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package starturl
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const defaultColumn = "url"
+
+// readSeeds reads URLs from r one at a time and passes them to visit.
+// The format is detected from the content: a JSON array or NDJSON as
+// written by the output modules, CSV if isCSV is set, or one URL per line.
+func readSeeds(ctx context.Context, r io.Reader, isCSV bool, column string, visit func(string)) error {
+	br := bufio.NewReader(r)
+
+	first, err := peek(br)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case first == '[' || first == '{':
+		return readJSON(ctx, br, first == '[', visit)
+	case isCSV:
+		return readCSV(ctx, br, column, visit)
+	default:
+		return readLines(ctx, br, visit)
+	}
+}
+
+// peek returns the first byte that is not whitespace.
+func peek(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b[0])) {
+			return b[0], nil
+		}
+		br.ReadByte()
+	}
+}
+
+func readJSON(ctx context.Context, r io.Reader, array bool, visit func(string)) error {
+	dec := json.NewDecoder(r)
+	if array {
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+
+	for ctx.Err() == nil {
+		if array && !dec.More() {
+			return nil
+		}
+
+		var v struct {
+			URL string `json:"url"`
+		}
+		if err := dec.Decode(&v); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if v.URL != "" {
+			visit(v.URL)
+		}
+	}
+	return ctx.Err()
+}
+
+func readCSV(ctx context.Context, r io.Reader, column string, visit func(string)) error {
+	if column == "" {
+		column = defaultColumn
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	idx := -1
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), column) {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return fmt.Errorf("column %q not found", column)
+	}
+
+	for ctx.Err() == nil {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if idx < len(record) {
+			visit(record[idx])
+		}
+	}
+	return ctx.Err()
+}
+
+func readLines(ctx context.Context, r io.Reader, visit func(string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+
+	for ctx.Err() == nil {
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			visit(line)
+		}
+	}
+	return ctx.Err()
+}
@@ -5,16 +5,34 @@
 package starturl
 
 import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/philippta/flyscrape"
 )
 
+// stdin is the value of urls or urlsFile that reads the URLs from stdin.
+const stdin = "-"
+
+// maxQueued is the number of queued jobs at which reading the URLs
+// pauses, until the scraper catches up.
+const maxQueued = 10_000
+
 func init() {
 	flyscrape.RegisterModule(Module{})
 }
 
 type Module struct {
-	URL  string   `json:"url"`
-	URLs []string `json:"urls"`
+	URL        string   `json:"url"`
+	URLs       []string `json:"urls"`
+	URLsFile   string   `json:"urlsFile"`
+	URLsColumn string   `json:"urlsColumn"`
 }
 
 func (Module) ModuleInfo() flyscrape.ModuleInfo {
@@ -24,14 +42,65 @@ func (Module) ModuleInfo() flyscrape.ModuleInfo {
 	}
 }
 
-func (m *Module) Provision(ctx flyscrape.Context) {
+func (m *Module) Provision(ctx flyscrape.Context) error {
 	if m.URL != "" {
 		ctx.Visit(m.URL)
 	}
 
 	for _, url := range m.URLs {
-		ctx.Visit(url)
+		if url != stdin {
+			ctx.Visit(url)
+		}
+	}
+	return nil
+}
+
+// Seed reads the URLs from the file or stdin. They are visited while
+// reading, so that large files are never held in memory. Reading pauses
+// while the queue holds more than maxQueued jobs.
+func (m *Module) Seed(ctx context.Context, v flyscrape.Context) {
+	if m.URLsFile != "" && m.URLsFile != stdin {
+		if err := m.readFile(ctx, m.URLsFile, v); err != nil {
+			slog.Error("failed to read urls", "file", m.URLsFile, "error", err)
+		}
+	}
+
+	if m.URLsFile == stdin || slices.Contains(m.URLs, stdin) {
+		if err := m.read(ctx, os.Stdin, m.URLsColumn != "", v); err != nil {
+			slog.Error("failed to read urls from stdin", "error", err)
+		}
 	}
 }
 
-var _ flyscrape.Provisioner = (*Module)(nil)
+func (m *Module) readFile(ctx context.Context, name string, v flyscrape.Context) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	isCSV := m.URLsColumn != "" || strings.EqualFold(filepath.Ext(name), ".csv")
+	return m.read(ctx, f, isCSV, v)
+}
+
+func (m *Module) read(ctx context.Context, r io.Reader, isCSV bool, v flyscrape.Context) error {
+	err := readSeeds(ctx, r, isCSV, m.URLsColumn, func(url string) {
+		for v.Queued() >= maxQueued {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		v.Visit(url)
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+var (
+	_ flyscrape.Provisioner = (*Module)(nil)
+	_ flyscrape.Seeder      = (*Module)(nil)
+)
@@ -5,9 +5,16 @@
 package starturl_test
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/philippta/flyscrape"
 	"github.com/philippta/flyscrape/modules/hook"
@@ -34,7 +41,7 @@ func TestStartURL(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Equal(t, "http://www.example.com/foo/bar", url)
 	require.Equal(t, 0, depth)
@@ -119,9 +126,152 @@ func TestStartURL_MultipleStartingURLs(t *testing.T) {
 
 			scraper := flyscrape.NewScraper()
 			scraper.Modules = mods
-			scraper.Run()
+			scraper.Run(context.Background())
 
 			require.ElementsMatch(t, tc.urls, urls)
 		})
 	}
 }
+
+func TestStartURL_File(t *testing.T) {
+	testCases := []struct {
+		name    string
+		file    string
+		content string
+		column  string
+	}{
+		{
+			name: "lines",
+			file: "urls.txt",
+			content: `
+			http://www.example.com/foo
+
+			http://www.example.com/bar
+			`,
+		},
+		{
+			name: "csv",
+			file: "urls.csv",
+			content: `name,url
+			foo,http://www.example.com/foo
+			bar,http://www.example.com/bar`,
+		},
+		{
+			name:   "csv column",
+			file:   "urls",
+			column: "link",
+			content: `link,name
+			http://www.example.com/foo,foo
+			http://www.example.com/bar,bar`,
+		},
+		{
+			name: "json",
+			file: "results.json",
+			content: `[
+			  {"url": "http://www.example.com/foo", "data": {"title": "Foo"}},
+			  {"url": "http://www.example.com/bar", "data": {"title": "Bar"}}
+			]`,
+		},
+		{
+			name: "ndjson",
+			file: "results.ndjson",
+			content: `{"url": "http://www.example.com/foo", "data": {"title": "Foo"}}
+			{"url": "http://www.example.com/bar", "data": {"title": "Bar"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), tc.file)
+			content := strings.ReplaceAll(tc.content, "\t", "")
+			require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+
+			urls := []string{}
+			mu := sync.Mutex{}
+
+			mods := []flyscrape.Module{
+				&starturl.Module{
+					URL:        "http://www.example.com/",
+					URLsFile:   file,
+					URLsColumn: tc.column,
+				},
+				hook.Module{
+					AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+						return flyscrape.MockTransport(http.StatusOK, "")
+					},
+					BuildRequestFn: func(r *flyscrape.Request) {
+						mu.Lock()
+						urls = append(urls, r.URL)
+						mu.Unlock()
+					},
+				},
+			}
+
+			scraper := flyscrape.NewScraper()
+			scraper.Modules = mods
+			scraper.Run(context.Background())
+
+			require.ElementsMatch(t, []string{
+				"http://www.example.com/",
+				"http://www.example.com/foo",
+				"http://www.example.com/bar",
+			}, urls)
+		})
+	}
+}
+
+func TestStartURL_FileQueueLimit(t *testing.T) {
+	var content strings.Builder
+	for i := range 25_000 {
+		fmt.Fprintf(&content, "http://www.example.com/%d\n", i)
+	}
+
+	file := filepath.Join(t.TempDir(), "urls.txt")
+	require.NoError(t, os.WriteFile(file, []byte(content.String()), 0644))
+
+	var ctx flyscrape.Context
+	var visited, maxQueued atomic.Int64
+	var full atomic.Bool
+
+	observe := func() {
+		if n := int64(ctx.Queued()); n > maxQueued.Load() {
+			maxQueued.Store(n)
+		}
+	}
+
+	mods := []flyscrape.Module{
+		&starturl.Module{URLsFile: file},
+		hook.Module{
+			ProvisionFn: func(c flyscrape.Context) {
+				ctx = c
+			},
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					// Hold back all requests until the queue is full, to
+					// see whether reading pauses.
+					deadline := time.Now().Add(5 * time.Second)
+					for !full.Load() && time.Now().Before(deadline) {
+						if ctx.Queued() >= 10_000 {
+							time.Sleep(50 * time.Millisecond)
+							full.Store(true)
+						}
+						time.Sleep(time.Millisecond)
+					}
+					observe()
+					return flyscrape.MockResponse(http.StatusOK, "")
+				})
+			},
+			BuildRequestFn: func(r *flyscrape.Request) {
+				visited.Add(1)
+			},
+		},
+	}
+
+	scraper := flyscrape.NewScraper()
+	scraper.Modules = mods
+	scraper.Run(context.Background())
+
+	require.True(t, full.Load())
+	require.Equal(t, int64(25_000), visited.Load())
+	require.LessOrEqual(t, maxQueued.Load(), int64(10_000))
+}
@@ -5,6 +5,7 @@
 package urlfilter
 
 import (
+	"fmt"
 	"regexp"
 
 	"github.com/philippta/flyscrape"
@@ -31,9 +32,9 @@ func (Module) ModuleInfo() flyscrape.ModuleInfo {
 	}
 }
 
-func (m *Module) Provision(v flyscrape.Context) {
+func (m *Module) Provision(v flyscrape.Context) error {
 	if m.disabled() {
-		return
+		return nil
 	}
 
 	for _, pat := range m.AllowedURLs {
@@ -51,48 +52,46 @@ func (m *Module) Provision(v flyscrape.Context) {
 		}
 		m.blockedURLsRE = append(m.blockedURLsRE, re)
 	}
+	return nil
 }
 
-func (m *Module) ValidateRequest(r *flyscrape.Request) bool {
+func (m *Module) ValidateRequest(r *flyscrape.Request) (bool, string) {
 	if m.disabled() {
-		return true
+		return true, ""
 	}
 
 	// allow root url
 	if r.URL == m.URL {
-		return true
+		return true, ""
 	}
 	for _, u := range m.URLs {
 		if r.URL == u {
-			return true
+			return true, ""
 		}
 	}
 
 	// allow if no filter is set
 	if len(m.allowedURLsRE) == 0 && len(m.blockedURLsRE) == 0 {
-		return true
+		return true, ""
 	}
 
-	ok := false
-	if len(m.allowedURLsRE) == 0 {
-		ok = true
-	}
-
-	for _, re := range m.allowedURLsRE {
+	for _, re := range m.blockedURLsRE {
 		if re.MatchString(r.URL) {
-			ok = true
-			break
+			return false, fmt.Sprintf("url matches blocked pattern %q", re.String())
 		}
 	}
 
-	for _, re := range m.blockedURLsRE {
+	if len(m.allowedURLsRE) == 0 {
+		return true, ""
+	}
+
+	for _, re := range m.allowedURLsRE {
 		if re.MatchString(r.URL) {
-			ok = false
-			break
+			return true, ""
 		}
 	}
 
-	return ok
+	return false, "url does not match any allowed pattern"
 }
 
 func (m *Module) disabled() bool {
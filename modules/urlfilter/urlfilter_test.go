@@ -5,6 +5,7 @@
 package urlfilter_test
 
 import (
+	"context"
 	"net/http"
 	"sync"
 	"testing"
@@ -46,7 +47,7 @@ func TestURLFilterAllowed(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Len(t, urls, 3)
 	require.Contains(t, urls, "http://www.example.com/")
@@ -83,7 +84,7 @@ func TestURLFilterBlocked(t *testing.T) {
 
 	scraper := flyscrape.NewScraper()
 	scraper.Modules = mods
-	scraper.Run()
+	scraper.Run(context.Background())
 
 	require.Len(t, urls, 3)
 	require.Contains(t, urls, "http://www.example.com/")
@@ -0,0 +1,308 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	progressInterval = 500 * time.Millisecond
+	progressWindow   = 10 * time.Second
+	progressHosts    = 10
+)
+
+// Progress is a snapshot of a running scraper.
+type Progress struct {
+	Queued    int `json:"queued"`
+	InFlight  int `json:"inFlight"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+
+	// Hosts are sorted by the number of requests, busiest first.
+	Hosts []HostProgress `json:"hosts"`
+}
+
+// HostProgress is the progress of the requests to a single host.
+type HostProgress struct {
+	Host      string `json:"host"`
+	InFlight  int    `json:"inFlight"`
+	Completed int    `json:"completed"`
+	Failed    int    `json:"failed"`
+}
+
+// Progress returns a snapshot of the progress of the current run.
+func (s *Scraper) Progress() Progress {
+	var p Progress
+
+	if s.jobs != nil {
+		p.Queued = s.jobs.len()
+	}
+	s.parkMu.Lock()
+	for _, pk := range s.parked {
+		p.Queued += len(pk.jobs)
+	}
+	s.parkMu.Unlock()
+
+	s.hostsMu.Lock()
+	for _, h := range s.hosts {
+		p.InFlight += h.InFlight
+		p.Completed += h.Completed
+		p.Failed += h.Failed
+		p.Hosts = append(p.Hosts, *h)
+	}
+	s.hostsMu.Unlock()
+
+	sort.Slice(p.Hosts, func(i, j int) bool {
+		a, b := p.Hosts[i], p.Hosts[j]
+		if na, nb := a.InFlight+a.Completed+a.Failed, b.InFlight+b.Completed+b.Failed; na != nb {
+			return na > nb
+		}
+		return a.Host < b.Host
+	})
+
+	return p
+}
+
+// startRequest counts a request to the host as in flight.
+func (s *Scraper) startRequest(host string) {
+	s.hostsMu.Lock()
+	defer s.hostsMu.Unlock()
+
+	h := s.hosts[host]
+	if h == nil {
+		h = &HostProgress{Host: host}
+		s.hosts[host] = h
+	}
+	h.InFlight++
+}
+
+// finishRequest counts a request to the host as done. Aborted requests
+// are neither completed nor failed.
+func (s *Scraper) finishRequest(host string, completed bool, err error) {
+	s.hostsMu.Lock()
+	defer s.hostsMu.Unlock()
+
+	h := s.hosts[host]
+	h.InFlight--
+	switch {
+	case !completed:
+	case err != nil:
+		h.Failed++
+	default:
+		h.Completed++
+	}
+}
+
+// progressView draws the progress of a scraper on a terminal. Log
+// messages written through it are printed above the view, as is the
+// output on stdout if it is the terminal as well.
+type progressView struct {
+	mu      sync.Mutex
+	out     *os.File
+	scraper *Scraper
+	lines   int
+	partial bool
+	samples []progressSample
+
+	stdout *os.File
+	pipe   *os.File
+	copied chan struct{}
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+type progressSample struct {
+	time      time.Time
+	completed int
+}
+
+// newProgressView returns a view on out, or nil if out is not a terminal.
+func newProgressView(out *os.File, scraper *Scraper) *progressView {
+	if !term.IsTerminal(int(out.Fd())) {
+		return nil
+	}
+	return &progressView{
+		out:     out,
+		scraper: scraper,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (v *progressView) start() {
+	v.captureStdout()
+
+	go func() {
+		defer close(v.stopped)
+
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-v.done:
+				return
+			case <-ticker.C:
+				v.draw()
+			}
+		}
+	}()
+}
+
+// stop stops drawing and removes the view from the terminal.
+func (v *progressView) stop() {
+	v.restoreStdout()
+
+	close(v.done)
+	<-v.stopped
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.clear()
+}
+
+// captureStdout redirects stdout through the view if it is a terminal,
+// so that the view is not drawn over the output.
+func (v *progressView) captureStdout() {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return
+	}
+
+	v.stdout, v.pipe = os.Stdout, w
+	v.copied = make(chan struct{})
+	os.Stdout = w
+
+	go func() {
+		defer close(v.copied)
+		defer r.Close()
+		io.Copy(writerFunc(func(p []byte) (int, error) {
+			return v.write(v.stdout, p)
+		}), r)
+	}()
+}
+
+func (v *progressView) restoreStdout() {
+	if v.pipe == nil {
+		return
+	}
+	os.Stdout = v.stdout
+	v.pipe.Close()
+	<-v.copied
+}
+
+func (v *progressView) Write(p []byte) (int, error) {
+	return v.write(v.out, p)
+}
+
+func (v *progressView) write(f *os.File, p []byte) (int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.clear()
+	if len(p) > 0 {
+		v.partial = p[len(p)-1] != '\n'
+	}
+	return f.Write(p)
+}
+
+func (v *progressView) draw() {
+	p := v.scraper.Progress()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// Wait for the line that is being written to be finished.
+	if v.partial {
+		return
+	}
+
+	lines := v.render(p, v.rate(p.Completed+p.Failed))
+
+	v.clear()
+	fmt.Fprint(v.out, strings.Join(lines, "\n")+"\n")
+	v.lines = len(lines)
+}
+
+// clear moves the cursor back to the start of the view and erases it.
+func (v *progressView) clear() {
+	if v.lines > 0 {
+		fmt.Fprintf(v.out, "\x1b[%dA\x1b[J", v.lines)
+		v.lines = 0
+	}
+}
+
+// rate returns the pages per second over the last few seconds.
+func (v *progressView) rate(done int) float64 {
+	now := time.Now()
+	v.samples = append(v.samples, progressSample{now, done})
+	for len(v.samples) > 1 && now.Sub(v.samples[0].time) > progressWindow {
+		v.samples = v.samples[1:]
+	}
+
+	first := v.samples[0]
+	if elapsed := now.Sub(first.time).Seconds(); elapsed > 0 {
+		return float64(done-first.completed) / elapsed
+	}
+	return 0
+}
+
+func (v *progressView) render(p Progress, rate float64) []string {
+	width, _, err := term.GetSize(int(v.out.Fd()))
+	if err != nil || width <= 0 {
+		width = 80
+	}
+
+	lines := []string{fmt.Sprintf(
+		"Queued %d   In flight %d   Completed %d   Failed %d   Rate %.1f pages/s",
+		p.Queued, p.InFlight, p.Completed, p.Failed, rate,
+	)}
+
+	hostWidth := 0
+	for i, h := range p.Hosts {
+		if i == progressHosts {
+			break
+		}
+		hostWidth = max(hostWidth, len(h.Host))
+	}
+
+	for i, h := range p.Hosts {
+		if i == progressHosts {
+			lines = append(lines, fmt.Sprintf("  and %d more hosts", len(p.Hosts)-i))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("  %-*s   in flight %d   completed %d   failed %d",
+			hostWidth, h.Host, h.InFlight, h.Completed, h.Failed))
+	}
+
+	// Lines must not wrap, or clearing the view would miss some.
+	for i, line := range lines {
+		if len(line) >= width {
+			lines[i] = line[:width-1]
+		}
+	}
+
+	return lines
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
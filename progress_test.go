@@ -0,0 +1,52 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/philippta/flyscrape"
+	"github.com/philippta/flyscrape/modules/followlinks"
+	"github.com/philippta/flyscrape/modules/hook"
+	"github.com/philippta/flyscrape/modules/starturl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress(t *testing.T) {
+	scraper := flyscrape.NewScraper()
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		&followlinks.Module{},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					switch r.URL.String() {
+					case "http://www.example.com/":
+						return flyscrape.MockResponse(200, `
+							<a href="/foo">foo</a>
+							<a href="/missing">missing</a>
+							<a href="http://other.example.com/">other</a>`)
+					case "http://www.example.com/missing":
+						return flyscrape.MockResponse(404, "")
+					}
+					return flyscrape.MockResponse(200, "ok")
+				})
+			},
+		},
+	}
+	scraper.Run(context.Background())
+
+	p := scraper.Progress()
+	require.Equal(t, 0, p.Queued)
+	require.Equal(t, 0, p.InFlight)
+	require.Equal(t, 3, p.Completed)
+	require.Equal(t, 1, p.Failed)
+	require.Equal(t, []flyscrape.HostProgress{
+		{Host: "www.example.com", Completed: 2, Failed: 1},
+		{Host: "other.example.com", Completed: 1},
+	}, p.Hosts)
+}
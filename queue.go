@@ -0,0 +1,184 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+)
+
+// queue is an unbounded FIFO queue of jobs. It keeps up to limit jobs
+// in memory and spills everything beyond that to a temporary file,
+// which is read back once the jobs in memory are used up.
+type queue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	closed bool
+
+	limit int
+	mem   []target
+
+	file    *os.File
+	w       *bufio.Writer
+	r       *bufio.Reader
+	spilled int
+
+	// onDrop is called with the number of spilled jobs that are lost
+	// because the spill file could not be read.
+	onDrop func(n int, err error)
+}
+
+func newQueue(limit int) *queue {
+	q := &queue{limit: limit}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push adds a job to the end of the queue.
+func (q *queue) push(t target) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// Once jobs have been spilled, new jobs have to go to the file as
+	// well, so that they are not dequeued before the spilled ones.
+	if q.spilled > 0 || len(q.mem) >= q.limit {
+		if err := q.spill(t); err != nil {
+			return err
+		}
+	} else {
+		q.mem = append(q.mem, t)
+	}
+
+	q.cond.Signal()
+	return nil
+}
+
+// pop removes the first job of the queue. It blocks until a job is
+// available and returns false once the queue is closed.
+func (q *queue) pop() (target, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		for len(q.mem) == 0 && q.spilled == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if q.closed {
+			return target{}, false
+		}
+
+		if len(q.mem) == 0 {
+			if err := q.unspill(); err != nil {
+				q.drop(err)
+			}
+		}
+
+		if len(q.mem) > 0 {
+			t := q.mem[0]
+			q.mem[0] = target{}
+			q.mem = q.mem[1:]
+			return t, true
+		}
+	}
+}
+
+// len returns the number of queued jobs.
+func (q *queue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.mem) + q.spilled
+}
+
+// close wakes up all waiting callers of pop and removes the spill file.
+func (q *queue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.cond.Broadcast()
+
+	if q.file != nil {
+		q.file.Close()
+		os.Remove(q.file.Name())
+	}
+}
+
+func (q *queue) spill(t target) error {
+	if q.file == nil {
+		f, err := os.CreateTemp("", "flyscrape-queue")
+		if err != nil {
+			return fmt.Errorf("failed to create queue file: %w", err)
+		}
+		q.file = f
+		q.w = bufio.NewWriter(f)
+		q.r = bufio.NewReader(io.NewSectionReader(f, 0, math.MaxInt64))
+	}
+
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	if _, err := q.w.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write queue file: %w", err)
+	}
+
+	q.spilled++
+	return nil
+}
+
+func (q *queue) unspill() error {
+	if err := q.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write queue file: %w", err)
+	}
+
+	for len(q.mem) < q.limit && q.spilled > 0 {
+		line, err := q.r.ReadBytes('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read queue file: %w", err)
+		}
+
+		var t target
+		if err := json.Unmarshal(line, &t); err != nil {
+			return err
+		}
+
+		q.mem = append(q.mem, t)
+		q.spilled--
+	}
+
+	// Start over with an empty file once it has been read entirely.
+	if q.spilled == 0 {
+		return q.reset()
+	}
+	return nil
+}
+
+// drop discards all spilled jobs.
+func (q *queue) drop(err error) {
+	n := q.spilled
+	q.spilled = 0
+	q.reset()
+
+	if q.onDrop != nil && n > 0 {
+		q.onDrop(n, err)
+	}
+}
+
+func (q *queue) reset() error {
+	if err := q.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := q.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	q.w.Reset(q.file)
+	q.r.Reset(io.NewSectionReader(q.file, 0, math.MaxInt64))
+	return nil
+}
@@ -0,0 +1,191 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Result is the outcome of scraping a single page.
+type Result struct {
+	URL       string
+	Data      any
+	Error     error
+	Timestamp time.Time
+}
+
+// Option configures a Runner.
+type Option func(*Runner)
+
+// WithConfig overrides values of the config exported by the script,
+// like {"depth": 2} or {"output.file": "results.json"}.
+func WithConfig(overrides map[string]any) Option {
+	return func(r *Runner) {
+		r.overrides = overrides
+	}
+}
+
+// WithDir sets the directory that imports of the script are resolved
+// from. It defaults to the working directory.
+func WithDir(dir string) Option {
+	return func(r *Runner) {
+		r.dir = dir
+	}
+}
+
+// WithClient sets the client that requests are sent with. Its transport
+// is wrapped by the transports of the modules.
+func WithClient(client *http.Client) Option {
+	return func(r *Runner) {
+		r.client = client
+	}
+}
+
+// WithName sets the name of the script, which is used to name files
+// next to it, like the cache file.
+func WithName(name string) Option {
+	return func(r *Runner) {
+		r.name = name
+	}
+}
+
+// Runner runs a scraping script from Go. Only the registered modules
+// are used, so import the ones you need, like:
+//
+//	import _ "github.com/philippta/flyscrape/modules/followlinks"
+//
+// The output modules write to stdout or a file and are usually left out
+// in favor of the results returned by Run.
+type Runner struct {
+	overrides map[string]any
+	dir       string
+	name      string
+	client    *http.Client
+
+	exports Exports
+	cfg     Config
+	wait    func()
+
+	mu      sync.Mutex
+	scraper *Scraper
+}
+
+// New compiles the script and returns a runner for it.
+func New(script string, opts ...Option) (*Runner, error) {
+	r := &Runner{dir: "."}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.client == nil {
+		r.client = &http.Client{}
+	}
+
+	imports, wait := NewJSLibrary(r.client)
+
+	exports, err := compile(script, r.dir, imports)
+	if err != nil {
+		wait()
+		return nil, fmt.Errorf("failed to compile script: %w", err)
+	}
+
+	r.exports = exports
+	r.cfg = updateCfgMultiple(exports.Config(), r.overrides)
+	r.wait = wait
+	return r, nil
+}
+
+// Run starts scraping and returns the results as they come in. The
+// channel is closed once the run is over, which is when all pages are
+// scraped or ctx is canceled. Results must be received for the run to
+// go on. Downloads of the script are waited for, also if Run fails. A
+// runner can only be run once.
+func (r *Runner) Run(ctx context.Context) (<-chan Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.scraper != nil {
+		return nil, errors.New("runner has already been run")
+	}
+
+	mods, opts, err := loadConfig(r.cfg)
+	if err != nil {
+		r.wait()
+		return nil, err
+	}
+	r.exports.SetRuntimes(opts.Runtimes)
+
+	results := make(chan Result)
+
+	scraper := NewScraper()
+	scraper.ScrapeFunc = r.exports.Scrape
+	scraper.PriorityFunc = r.exports.Priority()
+	scraper.SetupFunc = r.exports.Setup()
+	scraper.TeardownFunc = r.exports.Teardown()
+	scraper.ErrorFunc = r.exports.OnError()
+	scraper.Script = r.name
+	scraper.Client = r.client
+	scraper.Modules = append(withScriptModule(mods, r.exports), &resultModule{ctx: ctx, results: results})
+	scraper.Options = opts
+
+	if err := scraper.start(ctx); err != nil {
+		r.wait()
+		return nil, err
+	}
+	r.scraper = scraper
+
+	go func() {
+		scraper.wait(ctx)
+		r.wait()
+		close(results)
+	}()
+
+	return results, nil
+}
+
+// Stats returns the counters of the run, or nil if it has not started.
+func (r *Runner) Stats() *Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.scraper == nil {
+		return nil
+	}
+	return r.scraper.Stats()
+}
+
+// resultModule sends the scraped pages to the results channel.
+type resultModule struct {
+	ctx     context.Context
+	results chan<- Result
+}
+
+func (*resultModule) ModuleInfo() ModuleInfo {
+	return ModuleInfo{ID: "results"}
+}
+
+func (m *resultModule) ReceiveResponse(resp *Response) {
+	if resp.Duplicate || (resp.Error == nil && resp.Data == nil) {
+		return
+	}
+
+	result := Result{
+		URL:       resp.Request.URL,
+		Data:      resp.Data,
+		Error:     resp.Error,
+		Timestamp: time.Now(),
+	}
+
+	select {
+	case m.results <- result:
+	case <-m.ctx.Done():
+	}
+}
+
+var _ ResponseReceiver = (*resultModule)(nil)
@@ -0,0 +1,272 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/philippta/flyscrape"
+	_ "github.com/philippta/flyscrape/modules/cache"
+	_ "github.com/philippta/flyscrape/modules/depth"
+	_ "github.com/philippta/flyscrape/modules/followlinks"
+	_ "github.com/philippta/flyscrape/modules/ratelimit"
+	_ "github.com/philippta/flyscrape/modules/starturl"
+	"github.com/stretchr/testify/require"
+)
+
+const runnerScript = `
+export const config = {
+    url: "http://www.example.com/",
+    depth: 1,
+};
+
+export default function({ doc }) {
+    return { title: doc.find("title").text() };
+}
+`
+
+func TestRunner(t *testing.T) {
+	client := &http.Client{
+		Transport: flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if r.URL.Path == "/" {
+				return flyscrape.MockResponse(200, `<title>Home</title><a href="/about">About</a>`)
+			}
+			return flyscrape.MockResponse(200, `<title>About</title>`)
+		}),
+	}
+
+	runner, err := flyscrape.New(runnerScript, flyscrape.WithClient(client))
+	require.NoError(t, err)
+
+	results, err := runner.Run(context.Background())
+	require.NoError(t, err)
+
+	titles := map[string]any{}
+	for result := range results {
+		require.NoError(t, result.Error)
+		titles[result.URL] = result.Data.(map[string]any)["title"]
+	}
+
+	require.Equal(t, map[string]any{
+		"http://www.example.com/":      "Home",
+		"http://www.example.com/about": "About",
+	}, titles)
+	require.Equal(t, int64(2), runner.Stats().Summary().Pages)
+
+	_, err = runner.Run(context.Background())
+	require.Error(t, err)
+}
+
+func TestRunnerConfig(t *testing.T) {
+	client := &http.Client{Transport: flyscrape.MockTransport(200, `<a href="/about">About</a>`)}
+
+	runner, err := flyscrape.New(runnerScript,
+		flyscrape.WithClient(client),
+		flyscrape.WithConfig(map[string]any{"depth": 0}),
+	)
+	require.NoError(t, err)
+
+	results, err := runner.Run(context.Background())
+	require.NoError(t, err)
+
+	var n int
+	for range results {
+		n++
+	}
+	require.Equal(t, 1, n)
+}
+
+func TestRunnerCompileError(t *testing.T) {
+	_, err := flyscrape.New(`export default function( {`)
+	require.Error(t, err)
+}
+
+func TestRunnerProvisionError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "missing", "test.cache")
+
+	runner, err := flyscrape.New(runnerScript, flyscrape.WithConfig(map[string]any{"cache": "file:" + file}))
+	require.NoError(t, err)
+
+	_, err = runner.Run(context.Background())
+	require.ErrorContains(t, err, "cache:")
+}
+
+func TestRunnerConfigErrorDownloads(t *testing.T) {
+	client := &http.Client{
+		Transport: flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+			time.Sleep(50 * time.Millisecond)
+			return flyscrape.MockResponse(200, "file")
+		}),
+	}
+
+	dst := filepath.Join(t.TempDir(), "file.txt")
+	runner, err := flyscrape.New(fmt.Sprintf(`
+		import { download } from "flyscrape/http";
+
+		download("http://www.example.com/file.txt", %q);
+
+		export const config = { workers: "many" };
+		export default function() {}
+	`, dst), flyscrape.WithClient(client))
+	require.NoError(t, err)
+
+	// The download of the top-level code is finished once Run fails.
+	_, err = runner.Run(context.Background())
+	require.Error(t, err)
+	require.FileExists(t, dst)
+}
+
+func TestRunnerRequestHooks(t *testing.T) {
+	var requests []string
+	client := &http.Client{
+		Transport: flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+			requests = append(requests, r.Method+" "+r.URL.String()+" "+r.Header.Get("X-Signature"))
+			switch r.URL.Path {
+			case "/":
+				return flyscrape.MockResponse(200, `<a href="/a">A</a><a href="/b">B</a><a href="/admin">Admin</a>`)
+			case "/b":
+				return flyscrape.MockResponse(200, `<title>Not Found</title>`)
+			}
+			return flyscrape.MockResponse(200, `<title>Found</title>`)
+		}),
+	}
+
+	runner, err := flyscrape.New(`
+		export const config = { url: "http://www.example.com/", depth: 1, workers: 1 };
+
+		export async function beforeRequest(req) {
+			if (req.url.endsWith("/admin")) {
+				return false;
+			}
+			req.headers["X-Signature"] = "signed:" + req.url;
+		}
+
+		export function afterResponse({ status, body }) {
+			return status === 200 && !body.includes("Not Found");
+		}
+
+		export default function({ doc }) {
+			return { title: doc.find("title").text() };
+		}
+	`, flyscrape.WithClient(client))
+	require.NoError(t, err)
+
+	results, err := runner.Run(context.Background())
+	require.NoError(t, err)
+
+	var urls []string
+	for result := range results {
+		urls = append(urls, result.URL)
+	}
+
+	require.ElementsMatch(t, []string{"http://www.example.com/", "http://www.example.com/a"}, urls)
+	require.ElementsMatch(t, []string{
+		"GET http://www.example.com/ signed:http://www.example.com/",
+		"GET http://www.example.com/a signed:http://www.example.com/a",
+		"GET http://www.example.com/b signed:http://www.example.com/b",
+	}, requests)
+
+	summary := runner.Stats().Summary()
+	require.Equal(t, int64(2), summary.Pages)
+	require.Equal(t, int64(2), summary.Filtered["script"])
+}
+
+func TestRunnerRequestHooksFetch(t *testing.T) {
+	var mu sync.Mutex
+	signed := map[string]string{}
+	client := &http.Client{
+		Transport: flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+			mu.Lock()
+			signed[r.URL.Path] = r.Header.Get("X-Signature")
+			mu.Unlock()
+			return flyscrape.MockResponse(200, `<title>Page</title>`)
+		}),
+	}
+
+	// With a single runtime, the hook has to run on the runtime that is
+	// already busy with the script.
+	runner, err := flyscrape.New(`
+		export const config = {
+			urls: ["http://www.example.com/1", "http://www.example.com/2"],
+			runtimes: 1,
+		};
+
+		export function beforeRequest(req) {
+			req.headers["X-Signature"] = "signed";
+		}
+
+		export default async function({ scrape }) {
+			const res = await fetch("http://www.example.com/api");
+			const page = await scrape("/nested", ({ doc }) => doc.find("title").text());
+			return { api: res.status, page };
+		}
+	`, flyscrape.WithClient(client))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	results, err := runner.Run(ctx)
+	require.NoError(t, err)
+
+	var n int
+	for result := range results {
+		require.NoError(t, result.Error)
+		require.Equal(t, map[string]any{"api": float64(200), "page": "Page"}, result.Data)
+		n++
+	}
+	require.Equal(t, 2, n)
+	require.Equal(t, map[string]string{
+		"/1":      "signed",
+		"/2":      "signed",
+		"/api":    "signed",
+		"/nested": "signed",
+	}, signed)
+}
+
+func TestRunnerRequestHooksConcurrency(t *testing.T) {
+	client := &http.Client{
+		Transport: flyscrape.MockTransport(200, `<title>Page</title>`),
+	}
+
+	// The hook of the second page must not hold the only slot of the
+	// host while the first page fetches from the same host.
+	runner, err := flyscrape.New(`
+		export const config = {
+			urls: ["http://www.example.com/1", "http://www.example.com/2"],
+			concurrency: 1,
+			runtimes: 1,
+		};
+
+		export function beforeRequest(req) {}
+
+		export default async function() {
+			await new Promise((resolve) => setTimeout(resolve, 50));
+			const res = await fetch("http://www.example.com/api");
+			return res.status;
+		}
+	`, flyscrape.WithClient(client))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	results, err := runner.Run(ctx)
+	require.NoError(t, err)
+
+	var n int
+	for result := range results {
+		require.NoError(t, result.Error)
+		require.Equal(t, float64(200), result.Data)
+		n++
+	}
+	require.Equal(t, 2, n)
+}
@@ -0,0 +1,181 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const minScheduleWait = 10 * time.Millisecond
+
+type scheduledKey struct{}
+
+// IsScheduled reports whether a request has already been admitted by
+// all schedulers. Transport adapters that enforce the same limits as a
+// Scheduler must let these requests pass without waiting.
+func IsScheduled(r *http.Request) bool {
+	v, _ := r.Context().Value(scheduledKey{}).(bool)
+	return v
+}
+
+// parked holds the jobs of a host that is not ready yet.
+type parked struct {
+	jobs []target
+
+	// released is set when a parked job has been put back into the
+	// frontier. The next job of the host that is popped takes its turn.
+	released bool
+
+	timer *time.Timer
+}
+
+// schedule reserves a slot for the job with all schedulers. If its host
+// is not ready, the job is parked and false is returned. Parked jobs are
+// put back into the frontier one at a time, once the host might be ready.
+// The returned release function may be called more than once.
+func (s *Scraper) schedule(job target) (release func(), ok bool) {
+	var schedulers []Scheduler
+	for _, mod := range s.Modules {
+		if v, ok := mod.(Scheduler); ok {
+			schedulers = append(schedulers, v)
+		}
+	}
+	if len(schedulers) == 0 {
+		return func() {}, true
+	}
+
+	req := job.built
+	host := hostname(req.URL)
+
+	s.parkMu.Lock()
+	if p := s.parked[host]; p != nil {
+		if len(p.jobs) > 0 && !p.released {
+			// Queue up behind the jobs that are already waiting.
+			p.jobs = append(p.jobs, job)
+			s.parkMu.Unlock()
+			return nil, false
+		}
+		p.released = false
+	}
+	s.parkMu.Unlock()
+
+	var releases []func()
+	var wait time.Duration
+	for _, sched := range schedulers {
+		rel, w, ok := sched.Schedule(req)
+		if !ok {
+			wait = max(w, minScheduleWait)
+			for _, rel := range releases {
+				rel()
+			}
+			slog.Debug("host not ready, holding back url", "url", job.url, "wait", wait)
+			s.park(host, job, wait)
+			return nil, false
+		}
+		releases = append(releases, rel)
+	}
+
+	// The host is ready, so give the next waiting job a try as well.
+	s.unpark(host)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			for _, rel := range releases {
+				rel()
+			}
+			s.unpark(host)
+		})
+	}, true
+}
+
+// park holds the job back until wait has elapsed.
+func (s *Scraper) park(host string, job target, wait time.Duration) {
+	s.parkMu.Lock()
+	defer s.parkMu.Unlock()
+
+	p := s.parked[host]
+	if p == nil {
+		p = &parked{}
+		s.parked[host] = p
+	}
+
+	// The job was first in line, so it stays first.
+	p.jobs = append([]target{job}, p.jobs...)
+
+	if p.timer == nil {
+		p.timer = time.AfterFunc(wait, func() {
+			s.parkMu.Lock()
+			p.timer = nil
+			s.parkMu.Unlock()
+
+			s.unpark(host)
+		})
+	}
+}
+
+// unpark puts the first parked job of the host back into the frontier.
+func (s *Scraper) unpark(host string) {
+	s.parkMu.Lock()
+	p := s.parked[host]
+	if p == nil || len(p.jobs) == 0 {
+		s.parkMu.Unlock()
+		return
+	}
+
+	job := p.jobs[0]
+	p.jobs = p.jobs[1:]
+	p.released = true
+	if len(p.jobs) == 0 && p.timer == nil {
+		delete(s.parked, host)
+	}
+	s.parkMu.Unlock()
+
+	s.requeue(job)
+}
+
+// unparkAll puts all parked jobs back into the frontier.
+func (s *Scraper) unparkAll() {
+	s.parkMu.Lock()
+	var jobs []target
+	for host, p := range s.parked {
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+		jobs = append(jobs, p.jobs...)
+		delete(s.parked, host)
+	}
+	s.parkMu.Unlock()
+
+	for _, job := range jobs {
+		s.requeue(job)
+	}
+}
+
+// requeue puts a job that has already been counted back into the frontier.
+func (s *Scraper) requeue(job target) {
+	if err := s.jobs.push(job); err != nil {
+		slog.Error("failed to queue url", "url", job.url, "error", err)
+		s.dropped.Add(1)
+		s.wg.Done()
+	}
+}
+
+func withScheduled(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scheduledKey{}, true)
+}
+
+func hostname(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
@@ -5,13 +5,21 @@
 package flyscrape
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
+	"net/url"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cornelk/hashmap"
 )
@@ -21,14 +29,38 @@ type Context interface {
 	Visit(url string)
 	MarkVisited(url string)
 	MarkUnvisited(url string)
+	Canonicalize(url string) string
+	HTTPClient() *http.Client
+	Stats() *Stats
+
+	// Queued returns the number of jobs waiting to be scraped.
+	Queued() int
+
+	// Context returns the context of the run. Modules send their own
+	// requests with it, so that they are canceled along with the run.
+	Context() context.Context
 }
 
 type Request struct {
 	Method  string
 	URL     string
 	Headers http.Header
+	Body    []byte
 	Cookies http.CookieJar
 	Depth   int
+
+	// Data is the data attached to the URL when it was followed and
+	// Referrer the URL of the page it was followed from.
+	Data     any
+	Referrer string
+
+	// ctx is the context of requests sent by the script. It tells
+	// which runtime of the script sent the request.
+	ctx context.Context
+
+	// skip is the reason why a request builder skipped the request.
+	// Such requests are rejected once they are validated.
+	skip string
 }
 
 type Response struct {
@@ -39,54 +71,304 @@ type Response struct {
 	Error      error
 	Request    *Request
 
+	// Duplicate is set by modules that have seen the content before.
+	// Duplicates are not written to the output and their links are
+	// not followed.
+	Duplicate bool
+
 	Visit func(url string)
 }
 
+// FollowRequest is a request to follow from a scraped page. Method
+// defaults to GET. Data is passed on to the scrape function of the
+// followed page and must be encodable as JSON.
+type FollowRequest struct {
+	URL     string
+	Method  string
+	Body    []byte
+	Headers http.Header
+	Data    any
+}
+
 type target struct {
-	url   string
-	depth int
+	id       uint64
+	url      string
+	depth    int
+	method   string
+	body     []byte
+	headers  http.Header
+	data     any
+	referrer string
+	attempt  int
+
+	// built is the request once the modules built and validated it. It
+	// is kept while the job is held back by the schedulers.
+	built *Request
+}
+
+type targetJSON struct {
+	ID       uint64      `json:"id,omitempty"`
+	URL      string      `json:"url"`
+	Depth    int         `json:"depth"`
+	Method   string      `json:"method,omitempty"`
+	Body     []byte      `json:"body,omitempty"`
+	Headers  http.Header `json:"headers,omitempty"`
+	Data     any         `json:"data,omitempty"`
+	Referrer string      `json:"referrer,omitempty"`
+	Attempt  int         `json:"attempt,omitempty"`
+}
+
+func (t target) MarshalJSON() ([]byte, error) {
+	return json.Marshal(targetJSON{
+		ID:       t.id,
+		URL:      t.url,
+		Depth:    t.depth,
+		Method:   t.method,
+		Body:     t.body,
+		Headers:  t.headers,
+		Data:     t.data,
+		Referrer: t.referrer,
+		Attempt:  t.attempt,
+	})
+}
+
+func (t *target) UnmarshalJSON(b []byte) error {
+	var v targetJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	t.id, t.url, t.depth = v.ID, v.URL, v.Depth
+	t.method, t.body, t.headers = v.Method, v.Body, v.Headers
+	t.data, t.referrer, t.attempt = v.Data, v.Referrer, v.Attempt
+	return nil
+}
+
+func (t target) request() *Request {
+	method := t.method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	headers := http.Header{}
+	for k, v := range t.headers {
+		headers[k] = slices.Clone(v)
+	}
+
+	return &Request{
+		Method:   method,
+		URL:      t.url,
+		Headers:  headers,
+		Body:     t.body,
+		Depth:    t.depth,
+		Data:     t.data,
+		Referrer: t.referrer,
+	}
+}
+
+// Options configure the scraper itself. Like module settings,
+// they are read from the script config.
+type Options struct {
+	// Workers is the number of jobs processed concurrently.
+	Workers int `json:"workers"`
+
+	// Runtimes is the number of JavaScript runtimes that extract pages
+	// in parallel. It defaults to the number of CPUs. Each runtime runs
+	// the module-level code of the script on its own, so its side effects
+	// are repeated and its variables are not shared between them.
+	Runtimes int `json:"runtimes"`
+
+	// QueueSize is the number of queued jobs kept in memory.
+	// Any jobs beyond that are spilled to a temporary file.
+	QueueSize int `json:"queueSize"`
+
+	// Frontier is the order in which jobs are processed. One of
+	// FrontierBFS (default), FrontierDFS or FrontierPriority.
+	// Only the BFS frontier spills jobs to disk.
+	Frontier string `json:"frontier"`
+
+	// StripParams are the query parameters that are ignored when
+	// checking whether a URL was already visited, like "utm_*".
+	StripParams []string `json:"stripParams"`
+
+	// MaxPages, MaxBytes and MaxDuration limit the number of scraped
+	// pages, the downloaded bytes and the duration of a run. Once any
+	// of them is reached, the run stops and the queued jobs are dropped.
+	MaxPages    int      `json:"maxPages"`
+	MaxBytes    int64    `json:"maxBytes"`
+	MaxDuration Duration `json:"maxDuration"`
+
+	// MaxPagesPerDomain limits the number of scraped pages per host.
+	// Further URLs of a host are dropped once it is reached.
+	MaxPagesPerDomain int `json:"maxPagesPerDomain"`
 }
 
+const (
+	defaultWorkers   = 500
+	defaultQueueSize = 100_000
+)
+
 func NewScraper() *Scraper {
 	return &Scraper{}
 }
 
 type Scraper struct {
-	ScrapeFunc ScrapeFunc
-	Script     string
-	Modules    []Module
-	Client     *http.Client
+	ScrapeFunc   ScrapeFunc
+	PriorityFunc PriorityFunc
+	SetupFunc    SetupFunc
+	TeardownFunc TeardownFunc
+	ErrorFunc    ErrorFunc
+	Script       string
+	Modules      []Module
+	Client       *http.Client
+	State        *State
+	Options      Options
+
+	// MetricsAddr is the address that the metrics are served on during
+	// the run, see MetricsHandler.
+	MetricsAddr string
 
 	wg      sync.WaitGroup
-	jobs    chan target
+	jobs    frontier
 	visited *hashmap.Map[string, struct{}]
+	visitMu sync.Mutex
+	dropped atomic.Int64
+	stats   *Stats
+	ctx     context.Context
+
+	// setup is the result of SetupFunc.
+	setup any
+
+	// cleanups are called once the run is over.
+	cleanups []func()
+
+	parkMu sync.Mutex
+	parked map[string]*parked
+
+	hostsMu sync.Mutex
+	hosts   map[string]*HostProgress
+
+	stopOnce    sync.Once
+	stopped     atomic.Bool
+	pages       atomic.Int64
+	bytes       atomic.Int64
+	domainMu    sync.Mutex
+	domainPages map[string]int
 }
 
 func (s *Scraper) Visit(url string) {
-	s.enqueueJob(url, 0)
+	s.enqueueJob(target{url: url})
 }
 
 func (s *Scraper) MarkVisited(url string) {
-	s.visited.Insert(url, struct{}{})
+	s.markVisited(s.Canonicalize(url))
+}
+
+func (s *Scraper) markVisited(key string) {
+	s.visited.Insert(key, struct{}{})
+	if s.State != nil {
+		s.State.addVisited(key)
+	}
 }
 
 func (s *Scraper) MarkUnvisited(url string) {
+	url = s.Canonicalize(url)
 	s.visited.Del(url)
+	if s.State != nil {
+		s.State.removeVisited(url)
+	}
+}
+
+// Canonicalize returns the form of the URL that the visited set is keyed by.
+func (s *Scraper) Canonicalize(url string) string {
+	return Canonicalize(url, s.Options.StripParams)
 }
 
 func (s *Scraper) ScriptName() string {
 	return s.Script
 }
 
-func (s *Scraper) Run() {
-	s.jobs = make(chan target, 1<<20)
+// HTTPClient returns the client that requests are sent with, including
+// the transports of all modules.
+func (s *Scraper) HTTPClient() *http.Client {
+	return s.Client
+}
+
+func (s *Scraper) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// Stats returns the counters of the current or last run.
+func (s *Scraper) Stats() *Stats {
+	return s.stats
+}
+
+func (s *Scraper) Queued() int {
+	if s.jobs == nil {
+		return 0
+	}
+	return s.jobs.len()
+}
+
+// Run runs the scraper until all jobs are processed or ctx is canceled.
+// When canceled, no new jobs are started, in-flight requests are aborted
+// and all modules are finalized before Run returns. An error is only
+// returned if the metrics cannot be served, a module fails to provision
+// or the setup function fails, before any page is scraped.
+func (s *Scraper) Run(ctx context.Context) error {
+	if err := s.start(ctx); err != nil {
+		return err
+	}
+	s.wait(ctx)
+	return nil
+}
+
+// Stop shuts the run down gracefully. No new jobs are started, while
+// in-flight requests finish and their responses are received. Queued
+// jobs stay pending in the state. Canceling the context of Run aborts
+// the in-flight requests as well.
+func (s *Scraper) Stop() {
+	s.halt()
+}
+
+// start provisions the modules, runs the setup function and starts
+// the workers.
+func (s *Scraper) start(ctx context.Context) error {
+	s.ctx = ctx
+	s.stats = newStats()
+	s.initFrontier()
 	s.visited = hashmap.New[string, struct{}]()
+	s.parked = map[string]*parked{}
+	s.domainPages = map[string]int{}
+	s.hosts = map[string]*HostProgress{}
+
+	// The metrics are served before any module is provisioned, so that
+	// a taken address fails the run before it has any effect.
+	stopMetrics := func() {}
+	if s.MetricsAddr != "" {
+		stop, err := s.serveMetricsOn(s.MetricsAddr)
+		if err != nil {
+			s.jobs.close()
+			s.stats.finish()
+			return fmt.Errorf("metrics: %w", err)
+		}
+		stopMetrics = stop
+	}
 
 	s.initClient()
+	s.resume()
 
-	for _, mod := range s.Modules {
+	for i, mod := range s.Modules {
 		if v, ok := mod.(Provisioner); ok {
-			v.Provision(s)
+			if err := v.Provision(s); err != nil {
+				stopMetrics()
+				s.jobs.close()
+				s.finalize(s.Modules[:i])
+				s.stats.finish()
+				return fmt.Errorf("%s: %w", mod.ModuleInfo().ID, err)
+			}
 		}
 	}
 
@@ -95,18 +377,99 @@ func (s *Scraper) Run() {
 			s.Client.Transport = v.AdaptTransport(s.Client.Transport)
 		}
 	}
+	s.Client.Transport = statsTransport(s.stats, s.Client.Transport)
+
+	if err := s.runSetup(ctx); err != nil {
+		stopMetrics()
+		s.jobs.close()
+		s.finalize(s.Modules)
+		s.stats.finish()
+		return fmt.Errorf("setup: %w", err)
+	}
 
-	s.scrape()
-	s.wg.Wait()
-	close(s.jobs)
+	// Parked jobs are released right away, to be drained by the workers.
+	stop := context.AfterFunc(ctx, s.unparkAll)
+	s.cleanups = []func(){func() { stop() }, s.startLimits(), stopMetrics}
 
 	for _, mod := range s.Modules {
+		if v, ok := mod.(Seeder); ok {
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				v.Seed(ctx, s)
+			}()
+		}
+	}
+
+	s.scrape(ctx)
+	return nil
+}
+
+// wait waits for all jobs to be processed, runs the teardown function
+// and finalizes the modules.
+func (s *Scraper) wait(ctx context.Context) {
+	s.wg.Wait()
+	s.jobs.close()
+
+	if n := s.dropped.Load(); n > 0 {
+		slog.Warn("urls could not be queued and were dropped", "count", n)
+		s.stats.Add(StatDropped, n)
+	}
+	s.stats.finish()
+
+	// The teardown function may still send requests through the modules.
+	s.runTeardown(ctx)
+	s.finalize(s.Modules)
+
+	for _, cleanup := range s.cleanups {
+		cleanup()
+	}
+}
+
+func (s *Scraper) finalize(mods []Module) {
+	for _, mod := range mods {
 		if v, ok := mod.(Finalizer); ok {
 			v.Finalize()
 		}
 	}
 }
 
+func (s *Scraper) initFrontier() {
+	switch s.Options.Frontier {
+	case FrontierDFS:
+		s.jobs = newPriorityQueue(func(t target) float64 {
+			return float64(t.depth)
+		}, true)
+		return
+
+	case FrontierPriority:
+		if s.PriorityFunc != nil {
+			s.jobs = newPriorityQueue(func(t target) float64 {
+				return s.PriorityFunc(t.url, t.depth)
+			}, false)
+			return
+		}
+		slog.Warn("frontier: no priority function exported, falling back to bfs")
+
+	case FrontierBFS, "":
+	default:
+		slog.Warn("frontier: unknown frontier, falling back to bfs", "frontier", s.Options.Frontier)
+	}
+
+	size := s.Options.QueueSize
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+
+	q := newQueue(size)
+	q.onDrop = func(n int, err error) {
+		slog.Error("failed to read queued urls", "count", n, "error", err)
+		s.dropped.Add(int64(n))
+		s.wg.Add(-n)
+	}
+	s.jobs = q
+}
+
 func (s *Scraper) initClient() {
 	if s.Client == nil {
 		s.Client = &http.Client{}
@@ -119,32 +482,89 @@ func (s *Scraper) initClient() {
 	}
 }
 
-func (s *Scraper) scrape() {
-	for i := 0; i < 500; i++ {
+// resume restores the visited set and re-enqueues the pending jobs
+// recorded in the state of a previous run.
+func (s *Scraper) resume() {
+	if s.State == nil {
+		return
+	}
+
+	pending, visited, err := s.State.load()
+	if err != nil {
+		slog.Error("state: failed to load previous run", "error", err)
+		return
+	}
+
+	for _, url := range visited {
+		s.visited.Insert(url, struct{}{})
+	}
+
+	for _, job := range pending {
+		s.wg.Add(1)
+		if err := s.jobs.push(job); err != nil {
+			slog.Error("failed to queue url", "url", job.url, "error", err)
+			s.dropped.Add(1)
+			s.wg.Done()
+		}
+	}
+}
+
+func (s *Scraper) scrape(ctx context.Context) {
+	workers := s.Options.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+
+	for i := 0; i < workers; i++ {
 		go func() {
-			for job := range s.jobs {
-				s.process(job.url, job.depth)
+			for {
+				job, ok := s.jobs.pop()
+				if !ok {
+					return
+				}
+
+				// Jobs are drained without processing once canceled or
+				// stopped by a limit and stay pending in the state.
+				if ctx.Err() != nil || s.stopped.Load() {
+					s.wg.Done()
+					continue
+				}
+
+				if !s.prepare(&job) {
+					if s.State != nil {
+						s.State.removePending(job)
+					}
+					s.wg.Done()
+					continue
+				}
+
+				release, ok := s.schedule(job)
+				if !ok {
+					continue
+				}
+
+				completed := s.process(ctx, job, release)
+				release()
+
+				if completed && s.State != nil {
+					s.State.removePending(job)
+				}
 				s.wg.Done()
 			}
 		}()
 	}
 }
 
-func (s *Scraper) process(url string, depth int) {
-	request := &Request{
-		Method:  http.MethodGet,
-		URL:     url,
-		Headers: http.Header{},
-		Cookies: s.Client.Jar,
-		Depth:   depth,
+// prepare builds the request of a job and reports whether the modules
+// accept it. This happens before the job is scheduled, as building may
+// wait for the script, which in turn may wait for a slot of the host.
+func (s *Scraper) prepare(job *target) bool {
+	if job.built != nil {
+		return true
 	}
 
-	response := &Response{
-		Request: request,
-		Visit: func(url string) {
-			s.enqueueJob(url, depth+1)
-		},
-	}
+	request := job.request()
+	request.Cookies = s.Client.Jar
 
 	for _, mod := range s.Modules {
 		if v, ok := mod.(RequestBuilder); ok {
@@ -152,36 +572,119 @@ func (s *Scraper) process(url string, depth int) {
 		}
 	}
 
-	req, err := http.NewRequest(request.Method, request.URL, nil)
+	if s.validate(request) != nil {
+		return false
+	}
+	job.built = request
+	return true
+}
+
+// process fetches and scrapes a single URL. The scheduler slot is released
+// as soon as the response is read, so that nested requests of the scrape
+// function can use it. process reports whether the job was completed,
+// which is not the case when ctx was canceled midway.
+func (s *Scraper) process(ctx context.Context, job target, release func()) (completed bool) {
+	request := job.built
+
+	// Followed URLs are enqueued once all modules received the response,
+	// as scoring them may need to call into the script as well and the
+	// page may turn out to be a duplicate.
+	var follows []FollowRequest
+
+	// Errors of the script itself are not passed to its onError function.
+	var scriptFailed bool
+
+	// Responses rejected by a module are neither scraped nor reported.
+	var rejected bool
+
+	response := &Response{Request: request}
+	follow := func(f FollowRequest) {
+		if !response.Duplicate {
+			s.enqueueJob(target{
+				url:      f.URL,
+				depth:    job.depth + 1,
+				method:   strings.ToUpper(f.Method),
+				body:     f.Body,
+				headers:  f.Headers,
+				data:     f.Data,
+				referrer: request.URL,
+			})
+		}
+	}
+	response.Visit = func(url string) {
+		follow(FollowRequest{URL: url})
+	}
+
+	req, err := http.NewRequestWithContext(withScheduled(ctx), request.Method, request.URL, bytes.NewReader(request.Body))
 	if err != nil {
 		response.Error = err
-		return
+		return true
 	}
 	req.Header = request.Headers
 
-	for _, mod := range s.Modules {
-		if v, ok := mod.(RequestValidator); ok {
-			if !v.ValidateRequest(request) {
+	// Jobs that were not sent before the run was stopped stay pending.
+	if s.stopped.Load() {
+		return false
+	}
+
+	if !s.reserve(request.URL) {
+		slog.Debug("skipping url, limit reached", "url", request.URL)
+		s.stats.Add(StatFiltered+".limits", 1)
+		return true
+	}
+
+	host := hostname(request.URL)
+	s.startRequest(host)
+	defer func() {
+		s.finishRequest(host, completed, response.Error)
+	}()
+
+	defer func() {
+		// The slot is given up on every path, as onError may send
+		// requests to the same host.
+		release()
+
+		// Don't report responses of aborted requests.
+		if ctx.Err() != nil {
+			return
+		}
+		completed = true
+		if rejected {
+			return
+		}
+
+		if response.Error != nil && !scriptFailed && s.ErrorFunc != nil {
+			if s.handleError(ctx, job, response) {
 				return
 			}
 		}
-	}
+		s.stats.Add(StatPages, 1)
 
-	defer func() {
 		for _, mod := range s.Modules {
 			if v, ok := mod.(ResponseReceiver); ok {
 				v.ReceiveResponse(response)
 			}
 		}
+
+		if response.Duplicate {
+			slog.Debug("duplicate page, not following its links", "url", request.URL)
+		}
+		for _, f := range follows {
+			follow(f)
+		}
 	}()
 
+	slog.Debug("sending request", "method", request.Method, "url", request.URL, "depth", request.Depth)
+
 	resp, err := s.Client.Do(req)
 	if err != nil {
+		slog.Debug("request failed", "url", request.URL, "error", err)
 		response.Error = err
 		return
 	}
 	defer resp.Body.Close()
 
+	slog.Debug("response received", "url", request.URL, "status", resp.StatusCode)
 	response.StatusCode = resp.StatusCode
 	response.Headers = resp.Header
 
@@ -190,43 +693,97 @@ func (s *Scraper) process(url string, depth int) {
 	}
 
 	response.Body, err = io.ReadAll(resp.Body)
+	release()
+	s.download(len(response.Body))
 	if err != nil {
+		s.stats.Add(StatErrors+".read", 1)
 		response.Error = err
 		return
 	}
 
+	if !s.validateResponse(response) {
+		rejected = true
+		return
+	}
+
 	if s.ScrapeFunc != nil {
 		func() {
 			defer func() {
 				if r := recover(); r != nil {
-					log.Println(r)
+					slog.Error("scrape function panicked", "url", request.URL, "error", r)
 				}
 			}()
 
 			p := ScrapeParams{
-				HTML:    string(response.Body),
-				URL:     request.URL,
-				Process: s.processImmediate,
-				Follow: func(url string) {
-					s.enqueueJob(url, depth+1)
+				HTML:     string(response.Body),
+				URL:      request.URL,
+				Meta:     request.Data,
+				Referrer: request.Referrer,
+				Setup:    s.setup,
+				Process: func(reqctx context.Context, url string) ([]byte, error) {
+					return s.processImmediate(ctx, reqctx, url)
+				},
+				Fetch: func(req *http.Request) (*http.Response, error) {
+					return s.fetch(ctx, req)
+				},
+				Follow: func(f FollowRequest) {
+					follows = append(follows, f)
 				},
 			}
 
+			start := time.Now()
 			response.Data, err = s.ScrapeFunc(p)
+			s.stats.Observe(StatScriptDuration, "", time.Since(start))
 			if err != nil {
+				s.stats.Add(StatErrors+".script", 1)
 				response.Error = err
+				scriptFailed = true
 				return
 			}
 		}()
 	}
+
+	return
+}
+
+// processImmediate fetches a page for the script. The request is
+// created with reqctx and canceled along with ctx.
+func (s *Scraper) processImmediate(ctx, reqctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(reqctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.fetch(ctx, req)
+	if errors.Is(err, errRejected) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	return io.ReadAll(resp.Body)
 }
 
-func (s *Scraper) processImmediate(url string) ([]byte, error) {
+// fetch sends a request of the script. Like the requests of crawled
+// pages, it is built and validated by the modules first. The request is
+// canceled along with ctx.
+func (s *Scraper) fetch(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+
 	request := &Request{
-		Method:  http.MethodGet,
-		URL:     url,
-		Headers: http.Header{},
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: req.Header,
 		Cookies: s.Client.Jar,
+		ctx:     req.Context(),
 	}
 
 	for _, mod := range s.Modules {
@@ -235,54 +792,142 @@ func (s *Scraper) processImmediate(url string) ([]byte, error) {
 		}
 	}
 
-	req, err := http.NewRequest(request.Method, request.URL, nil)
+	if err := s.validate(request); err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(request.URL)
 	if err != nil {
 		return nil, err
 	}
+
+	reqctx, cancel := context.WithCancel(req.Context())
+	stop := context.AfterFunc(ctx, cancel)
+
+	req = req.WithContext(reqctx)
+	req.Method = request.Method
+	req.URL = u
+	req.Host = u.Host
 	req.Header = request.Headers
 
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &fetchBody{ReadCloser: resp.Body, s: s, done: func() {
+		stop()
+		cancel()
+	}}
+	return resp, nil
+}
+
+// fetchBody counts the downloaded bytes of a fetched response and frees
+// its context once closed.
+type fetchBody struct {
+	io.ReadCloser
+	s    *Scraper
+	done func()
+}
+
+func (b *fetchBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.s.download(n)
+	return n, err
+}
+
+func (b *fetchBody) Close() error {
+	b.done()
+	return b.ReadCloser.Close()
+}
+
+// errRejected is returned for requests that a module rejected.
+var errRejected = errors.New("request rejected")
+
+// validate returns an error if a module rejects the request. Rejections
+// are counted by module.
+func (s *Scraper) validate(request *Request) error {
 	for _, mod := range s.Modules {
 		if v, ok := mod.(RequestValidator); ok {
-			if !v.ValidateRequest(request) {
-				return nil, nil
+			if ok, reason := v.ValidateRequest(request); !ok {
+				id := mod.ModuleInfo().ID
+				slog.Debug("skipping url, rejected by module", "url", request.URL, "module", id, "reason", reason)
+				s.stats.Add(StatFiltered+"."+id, 1)
+				return fmt.Errorf("%w by %s: %s", errRejected, id, reason)
 			}
 		}
 	}
+	return nil
+}
 
-	resp, err := s.Client.Do(req)
-	if err != nil {
-		return nil, err
+// validateResponse reports whether the response is scraped. Like those
+// of requests, rejections are counted by module.
+func (s *Scraper) validateResponse(response *Response) bool {
+	for _, mod := range s.Modules {
+		if v, ok := mod.(ResponseValidator); ok {
+			if ok, reason := v.ValidateResponse(response); !ok {
+				id := mod.ModuleInfo().ID
+				slog.Debug("skipping response, rejected by module", "url", response.Request.URL, "module", id, "reason", reason)
+				s.stats.Add(StatFiltered+"."+id, 1)
+				return false
+			}
+		}
 	}
-	defer resp.Body.Close()
+	return true
+}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+func (s *Scraper) enqueueJob(job target) {
+	job.url = strings.TrimSpace(job.url)
+	if job.url == "" {
+		return
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	// The URL is marked as visited right away, so that it is not queued
+	// twice by workers that follow it at the same time. GetOrInsert of
+	// the hashmap can miss existing keys, hence the lock.
+	key := s.key(job)
+	s.visitMu.Lock()
+	if _, ok := s.visited.Get(key); ok {
+		s.visitMu.Unlock()
+		slog.Debug("skipping url, already visited", "url", job.url)
+		return
 	}
+	s.visited.Insert(key, struct{}{})
+	s.visitMu.Unlock()
 
-	return body, nil
-}
-
-func (s *Scraper) enqueueJob(url string, depth int) {
-	url = strings.TrimSpace(url)
-	if url == "" {
+	if !s.accepts(job.url) {
+		slog.Debug("skipping url, limit reached", "url", job.url)
+		s.visited.Del(key)
 		return
 	}
 
-	if _, ok := s.visited.Get(url); ok {
+	if s.State != nil {
+		s.State.addPending(&job)
+	}
+
+	if !s.push(job) {
+		s.visited.Del(key)
 		return
 	}
+	if s.State != nil {
+		s.State.addVisited(key)
+	}
+}
 
+// push queues a job that was added to the state. It reports whether
+// the job was queued.
+func (s *Scraper) push(job target) bool {
 	s.wg.Add(1)
-	select {
-	case s.jobs <- target{url: url, depth: depth}:
-		s.MarkVisited(url)
-	default:
-		log.Println("queue is full, can't add url:", url)
+	if err := s.jobs.push(job); err != nil {
+		slog.Error("failed to queue url", "url", job.url, "error", err)
+		if s.State != nil {
+			s.State.removePending(job)
+		}
+		s.dropped.Add(1)
 		s.wg.Done()
+		return false
 	}
+	s.stats.Add(StatEnqueued, 1)
+	return true
 }
@@ -0,0 +1,571 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/philippta/flyscrape"
+	"github.com/philippta/flyscrape/modules/followlinks"
+	"github.com/philippta/flyscrape/modules/hook"
+	"github.com/philippta/flyscrape/modules/ratelimit"
+	"github.com/philippta/flyscrape/modules/starturl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScraperCancel(t *testing.T) {
+	var requested, received []string
+	var finalized bool
+
+	// The first interrupt stops the run, but lets the in-flight request
+	// finish. Its links are not followed anymore.
+	scraper := flyscrape.NewScraper()
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		&followlinks.Module{},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					requested = append(requested, r.URL.String())
+					scraper.Stop()
+					return flyscrape.MockResponse(200, `<a href="/foo">foo</a>`)
+				})
+			},
+			ReceiveResponseFn: func(r *flyscrape.Response) {
+				received = append(received, r.Request.URL)
+			},
+			FinalizeFn: func() {
+				finalized = true
+			},
+		},
+	}
+	require.NoError(t, scraper.Run(context.Background()))
+
+	require.Equal(t, []string{"http://www.example.com/"}, requested)
+	require.Equal(t, []string{"http://www.example.com/"}, received)
+	require.True(t, finalized)
+}
+
+func TestScraperAbort(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var received, finalized bool
+
+	// Canceling the context, as on the second interrupt, aborts the
+	// in-flight request as well.
+	scraper := flyscrape.NewScraper()
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com"},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					scraper.Stop()
+					cancel()
+					<-r.Context().Done()
+					return nil, r.Context().Err()
+				})
+			},
+			ReceiveResponseFn: func(r *flyscrape.Response) {
+				received = true
+			},
+			FinalizeFn: func() {
+				finalized = true
+			},
+		},
+	}
+	scraper.Run(ctx)
+
+	require.False(t, received)
+	require.True(t, finalized)
+}
+
+func TestScraperQueueSpill(t *testing.T) {
+	var links strings.Builder
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&links, `<a href="/%d">%d</a>`, i, i)
+	}
+
+	var urls []string
+	var mu sync.Mutex
+
+	scraper := flyscrape.NewScraper()
+	scraper.Options.QueueSize = 2
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		&followlinks.Module{},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					mu.Lock()
+					urls = append(urls, r.URL.String())
+					mu.Unlock()
+
+					if r.URL.Path == "/" {
+						return flyscrape.MockResponse(200, links.String())
+					}
+					return flyscrape.MockResponse(200, "")
+				})
+			},
+		},
+	}
+	scraper.Run(context.Background())
+
+	require.Len(t, urls, 51)
+	for i := 0; i < 50; i++ {
+		require.Contains(t, urls, fmt.Sprintf("http://www.example.com/%d", i))
+	}
+}
+
+func TestScraperWorkers(t *testing.T) {
+	var active, maxActive atomic.Int32
+
+	scraper := flyscrape.NewScraper()
+	scraper.Options.Workers = 1
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URLs: []string{
+			"http://www.example.com/a",
+			"http://www.example.com/b",
+			"http://www.example.com/c",
+		}},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					n := active.Add(1)
+					defer active.Add(-1)
+					if n > maxActive.Load() {
+						maxActive.Store(n)
+					}
+
+					time.Sleep(10 * time.Millisecond)
+					return flyscrape.MockResponse(200, "")
+				})
+			},
+		},
+	}
+	scraper.Run(context.Background())
+
+	require.Equal(t, int32(1), maxActive.Load())
+}
+
+func TestScraperFrontier(t *testing.T) {
+	pages := map[string]string{
+		"/":  `<a href="/a">A</a><a href="/b">B</a>`,
+		"/a": `<a href="/a/1">A1</a>`,
+		"/b": `<a href="/b/1">B1</a>`,
+	}
+
+	tests := []struct {
+		frontier string
+		priority flyscrape.PriorityFunc
+		paths    []string
+	}{
+		{
+			frontier: flyscrape.FrontierBFS,
+			paths:    []string{"/", "/a", "/b", "/a/1", "/b/1"},
+		},
+		{
+			frontier: flyscrape.FrontierDFS,
+			paths:    []string{"/", "/b", "/b/1", "/a", "/a/1"},
+		},
+		{
+			frontier: flyscrape.FrontierPriority,
+			priority: func(url string, depth int) float64 {
+				if strings.Contains(url, "/b") {
+					return float64(-depth) + 0.5
+				}
+				return float64(-depth)
+			},
+			paths: []string{"/", "/b", "/a", "/b/1", "/a/1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.frontier, func(t *testing.T) {
+			var paths []string
+
+			scraper := flyscrape.NewScraper()
+			scraper.PriorityFunc = test.priority
+			scraper.Options.Workers = 1
+			scraper.Options.Frontier = test.frontier
+			scraper.Modules = []flyscrape.Module{
+				&starturl.Module{URL: "http://www.example.com/"},
+				&followlinks.Module{},
+				hook.Module{
+					AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+						return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+							paths = append(paths, r.URL.Path)
+							return flyscrape.MockResponse(200, pages[r.URL.Path])
+						})
+					},
+				},
+			}
+			scraper.Run(context.Background())
+
+			require.Equal(t, test.paths, paths)
+		})
+	}
+}
+
+func TestScraperStripParams(t *testing.T) {
+	var urls []string
+	var mu sync.Mutex
+
+	scraper := flyscrape.NewScraper()
+	scraper.Options.StripParams = []string{"utm_*"}
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URLs: []string{
+			"http://www.example.com/?utm_source=a",
+			"http://www.example.com/?utm_source=b",
+			"http://www.example.com/?page=2&utm_medium=c",
+		}},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					mu.Lock()
+					urls = append(urls, r.URL.String())
+					mu.Unlock()
+					return flyscrape.MockResponse(200, "")
+				})
+			},
+		},
+	}
+	scraper.Run(context.Background())
+
+	require.Len(t, urls, 2)
+}
+
+func TestScraperFollowRequest(t *testing.T) {
+	var requests []string
+	var mu sync.Mutex
+
+	scraper := flyscrape.NewScraper()
+	scraper.ScrapeFunc = func(p flyscrape.ScrapeParams) (any, error) {
+		if p.URL != "http://www.example.com/" {
+			return nil, nil
+		}
+		for _, page := range []string{"1", "2", "2"} {
+			p.Follow(flyscrape.FollowRequest{
+				URL:     "http://www.example.com/search",
+				Method:  "post",
+				Body:    []byte("page=" + page),
+				Headers: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			})
+		}
+		p.Follow(flyscrape.FollowRequest{URL: "http://www.example.com/search"})
+		return nil, nil
+	}
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					var body []byte
+					if r.Body != nil {
+						body, _ = io.ReadAll(r.Body)
+					}
+
+					mu.Lock()
+					requests = append(requests, fmt.Sprintf("%s %s %s %s", r.Method, r.URL, r.Header.Get("Content-Type"), body))
+					mu.Unlock()
+					return flyscrape.MockResponse(200, "")
+				})
+			},
+		},
+	}
+	scraper.Run(context.Background())
+
+	require.ElementsMatch(t, []string{
+		"GET http://www.example.com/  ",
+		"POST http://www.example.com/search application/x-www-form-urlencoded page=1",
+		"POST http://www.example.com/search application/x-www-form-urlencoded page=2",
+		"GET http://www.example.com/search  ",
+	}, requests)
+}
+
+func TestScraperFollowData(t *testing.T) {
+	var meta []any
+	var referrers []string
+	var mu sync.Mutex
+
+	scraper := flyscrape.NewScraper()
+	scraper.ScrapeFunc = func(p flyscrape.ScrapeParams) (any, error) {
+		if p.URL == "http://www.example.com/" {
+			p.Follow(flyscrape.FollowRequest{
+				URL:  "http://www.example.com/foo",
+				Data: map[string]any{"position": 1},
+			})
+			return nil, nil
+		}
+
+		mu.Lock()
+		meta = append(meta, p.Meta)
+		referrers = append(referrers, p.Referrer)
+		mu.Unlock()
+		return nil, nil
+	}
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.MockTransport(200, "")
+			},
+		},
+	}
+	scraper.Run(context.Background())
+
+	require.Equal(t, []any{map[string]any{"position": 1}}, meta)
+	require.Equal(t, []string{"http://www.example.com/"}, referrers)
+}
+
+func TestScraperFetch(t *testing.T) {
+	exports, err := flyscrape.Compile(`
+		export default async function({ url }) {
+			const api = await fetch("http://www.example.com/api");
+			let blocked;
+			try {
+				await fetch("http://www.example.com/blocked");
+			} catch (err) {
+				blocked = err.message;
+			}
+			return { api: await api.text(), blocked };
+		}
+	`, nil)
+	require.NoError(t, err)
+
+	var data any
+
+	scraper := flyscrape.NewScraper()
+	scraper.ScrapeFunc = exports.Scrape
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		hook.Module{
+			BuildRequestFn: func(r *flyscrape.Request) {
+				r.Headers.Set("X-Built", "true")
+			},
+			ValidateRequestFn: func(r *flyscrape.Request) bool {
+				return !strings.HasSuffix(r.URL, "/blocked")
+			},
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					return flyscrape.MockResponse(200, r.URL.Path+" "+r.Header.Get("X-Built"))
+				})
+			},
+			ReceiveResponseFn: func(r *flyscrape.Response) {
+				data = r.Data
+			},
+		},
+	}
+	require.NoError(t, scraper.Run(context.Background()))
+
+	require.Equal(t, map[string]any{
+		"api":     "/api true",
+		"blocked": "fetch failed: request rejected by hook: rejected by hook",
+	}, data)
+	require.Equal(t, int64(2), scraper.Stats().Get(flyscrape.StatRequests))
+}
+
+func TestScraperLifecycle(t *testing.T) {
+	exports, err := flyscrape.Compile(`
+		export async function setup() {
+			const res = await fetch("http://www.example.com/login", { method: "POST" });
+			return { token: await res.text() };
+		}
+
+		export default function({ url, setup }) {
+			return { url, token: setup.token };
+		}
+
+		export async function teardown(summary, setup) {
+			await fetch("http://www.example.com/logout?pages=" + summary.pages + "&token=" + setup.token);
+		}
+
+		export function onError({ url, status, error, attempt, setup }) {
+			return { url, status, error, attempt, token: setup.token };
+		}
+	`, nil)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var requests []string
+	var data []any
+
+	scraper := flyscrape.NewScraper()
+	scraper.ScrapeFunc = exports.Scrape
+	scraper.SetupFunc = exports.Setup()
+	scraper.TeardownFunc = exports.Teardown()
+	scraper.ErrorFunc = exports.OnError()
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		&followlinks.Module{},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					mu.Lock()
+					requests = append(requests, r.Method+" "+r.URL.String())
+					mu.Unlock()
+
+					switch r.URL.Path {
+					case "/login":
+						return flyscrape.MockResponse(200, "secret")
+					case "/missing":
+						return flyscrape.MockResponse(404, "")
+					}
+					return flyscrape.MockResponse(200, `<a href="/missing">`)
+				})
+			},
+			ReceiveResponseFn: func(r *flyscrape.Response) {
+				mu.Lock()
+				data = append(data, r.Data)
+				mu.Unlock()
+			},
+		},
+	}
+	require.NoError(t, scraper.Run(context.Background()))
+
+	require.Equal(t, []string{
+		"POST http://www.example.com/login",
+		"GET http://www.example.com/",
+		"GET http://www.example.com/missing",
+		"GET http://www.example.com/logout?pages=2&token=secret",
+	}, requests)
+
+	require.ElementsMatch(t, []any{
+		map[string]any{"url": "http://www.example.com/", "token": "secret"},
+		map[string]any{
+			"url":     "http://www.example.com/missing",
+			"status":  float64(404),
+			"error":   "404 Not Found",
+			"attempt": float64(0),
+			"token":   "secret",
+		},
+	}, data)
+}
+
+func TestScraperOnErrorRetry(t *testing.T) {
+	exports, err := flyscrape.Compile(`
+		export default function({ url }) {
+			return { url };
+		}
+
+		export function onError({ attempt, retry }) {
+			if (attempt < 2) {
+				retry();
+			}
+		}
+	`, nil)
+	require.NoError(t, err)
+
+	var calls atomic.Int64
+	var statuses []int
+
+	scraper := flyscrape.NewScraper()
+	scraper.ScrapeFunc = exports.Scrape
+	scraper.ErrorFunc = exports.OnError()
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					if calls.Add(1) < 3 {
+						return flyscrape.MockResponse(503, "")
+					}
+					return flyscrape.MockResponse(200, "")
+				})
+			},
+			ReceiveResponseFn: func(r *flyscrape.Response) {
+				statuses = append(statuses, r.StatusCode)
+			},
+		},
+	}
+	require.NoError(t, scraper.Run(context.Background()))
+
+	require.Equal(t, int64(3), calls.Load())
+	require.Equal(t, []int{200}, statuses)
+	require.Equal(t, int64(1), scraper.Stats().Get(flyscrape.StatPages))
+}
+
+func TestScraperOnErrorFetch(t *testing.T) {
+	exports, err := flyscrape.Compile(`
+		export default function() {}
+
+		export async function onError() {
+			const res = await fetch("http://www.example.com/status");
+			return { status: res.status };
+		}
+	`, nil)
+	require.NoError(t, err)
+
+	var data []any
+
+	// The failed job must not hold the only slot of the host while
+	// onError fetches from it.
+	scraper := flyscrape.NewScraper()
+	scraper.ScrapeFunc = exports.Scrape
+	scraper.ErrorFunc = exports.OnError()
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					if r.URL.Path == "/" {
+						return nil, errors.New("connection reset")
+					}
+					return flyscrape.MockResponse(200, "")
+				})
+			},
+			ReceiveResponseFn: func(r *flyscrape.Response) {
+				data = append(data, r.Data)
+			},
+		},
+		&ratelimit.Module{Concurrency: ratelimit.Limit{Default: 1}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, scraper.Run(ctx))
+
+	require.Equal(t, []any{map[string]any{"status": float64(200)}}, data)
+}
+
+func TestScraperSetupError(t *testing.T) {
+	exports, err := flyscrape.Compile(`
+		export function setup() {
+			throw new Error("login failed");
+		}
+		export default function() {}
+	`, nil)
+	require.NoError(t, err)
+
+	var finalized bool
+
+	scraper := flyscrape.NewScraper()
+	scraper.ScrapeFunc = exports.Scrape
+	scraper.SetupFunc = exports.Setup()
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.MockTransport(200, "")
+			},
+			FinalizeFn: func() {
+				finalized = true
+			},
+		},
+	}
+
+	err = scraper.Run(context.Background())
+	require.EqualError(t, err, "setup: Error: login failed")
+	require.True(t, finalized)
+	require.Equal(t, int64(0), scraper.Stats().Get(flyscrape.StatRequests))
+}
@@ -0,0 +1,215 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	statePending = []byte("pending")
+	stateVisited = []byte("visited")
+)
+
+// State persists the pending jobs and the visited set of a run,
+// so that an interrupted run can be resumed where it stopped.
+//
+// Writes are queued and committed in groups by a single goroutine, so
+// that the file is synced once per group instead of once per URL.
+type State struct {
+	db     *bbolt.DB
+	nextID atomic.Uint64
+	writes chan stateWrite
+	done   chan struct{}
+	once   sync.Once
+}
+
+// stateWrite is a queued write. Its error is logged with the message.
+type stateWrite struct {
+	apply func(tx *bbolt.Tx) error
+	msg   string
+	url   string
+}
+
+// maxStateBatch is the maximum number of writes committed at once.
+const maxStateBatch = 1000
+
+// NewState opens the state file, creating it if it does not exist.
+func NewState(file string) (*State, error) {
+	db, err := bbolt.Open(file, 0644, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state file %q: %w", file, err)
+	}
+
+	var seq uint64
+	err = db.Update(func(tx *bbolt.Tx) error {
+		pending, err := tx.CreateBucketIfNotExists(statePending)
+		if err != nil {
+			return err
+		}
+		seq = pending.Sequence()
+		_, err = tx.CreateBucketIfNotExists(stateVisited)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize state file %q: %w", file, err)
+	}
+
+	s := &State{
+		db:     db,
+		writes: make(chan stateWrite, maxStateBatch),
+		done:   make(chan struct{}),
+	}
+	s.nextID.Store(seq)
+	go s.write()
+	return s, nil
+}
+
+// Close commits the queued writes and closes the state file.
+func (s *State) Close() error {
+	s.flush()
+	return s.db.Close()
+}
+
+// Remove closes and deletes the state file.
+func (s *State) Remove() error {
+	path := s.db.Path()
+	if err := s.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (s *State) flush() {
+	s.once.Do(func() { close(s.writes) })
+	<-s.done
+}
+
+// write commits the queued writes. Each transaction takes all writes
+// that are queued at that time.
+func (s *State) write() {
+	defer close(s.done)
+
+	for w := range s.writes {
+		batch := []stateWrite{w}
+	drain:
+		for len(batch) < maxStateBatch {
+			select {
+			case w, ok := <-s.writes:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, w)
+			default:
+				break drain
+			}
+		}
+
+		err := s.db.Update(func(tx *bbolt.Tx) error {
+			for _, w := range batch {
+				if err := w.apply(tx); err != nil {
+					slog.Error(w.msg, "url", w.url, "error", err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			slog.Error("state: failed to write", "count", len(batch), "error", err)
+		}
+	}
+}
+
+func (s *State) load() (pending []target, visited []string, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		err := tx.Bucket(stateVisited).ForEach(func(k, _ []byte) error {
+			visited = append(visited, string(k))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(statePending).ForEach(func(k, v []byte) error {
+			var t target
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+			t.id = binary.BigEndian.Uint64(k)
+			pending = append(pending, t)
+			return nil
+		})
+	})
+	return
+}
+
+func (s *State) addPending(t *target) {
+	t.id = s.nextID.Add(1)
+	id := t.id
+
+	v, err := json.Marshal(t)
+	if err != nil {
+		slog.Error("state: failed to add pending url", "url", t.url, "error", err)
+		return
+	}
+
+	s.writes <- stateWrite{
+		apply: func(tx *bbolt.Tx) error {
+			bucket := tx.Bucket(statePending)
+			if id > bucket.Sequence() {
+				if err := bucket.SetSequence(id); err != nil {
+					return err
+				}
+			}
+			return bucket.Put(stateKey(id), v)
+		},
+		msg: "state: failed to add pending url",
+		url: t.url,
+	}
+}
+
+func (s *State) removePending(t target) {
+	s.writes <- stateWrite{
+		apply: func(tx *bbolt.Tx) error {
+			return tx.Bucket(statePending).Delete(stateKey(t.id))
+		},
+		msg: "state: failed to remove pending url",
+		url: t.url,
+	}
+}
+
+func (s *State) addVisited(url string) {
+	s.writes <- stateWrite{
+		apply: func(tx *bbolt.Tx) error {
+			return tx.Bucket(stateVisited).Put([]byte(url), nil)
+		},
+		msg: "state: failed to add visited url",
+		url: url,
+	}
+}
+
+func (s *State) removeVisited(url string) {
+	s.writes <- stateWrite{
+		apply: func(tx *bbolt.Tx) error {
+			return tx.Bucket(stateVisited).Delete([]byte(url))
+		},
+		msg: "state: failed to remove visited url",
+		url: url,
+	}
+}
+
+func stateKey(id uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return b
+}
@@ -0,0 +1,149 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape_test
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/philippta/flyscrape"
+	"github.com/philippta/flyscrape/modules/followlinks"
+	"github.com/philippta/flyscrape/modules/hook"
+	"github.com/philippta/flyscrape/modules/starturl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateResume(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "test.state")
+
+	run := func(start *starturl.Module) []string {
+		var urls []string
+		var mu sync.Mutex
+
+		state, err := flyscrape.NewState(file)
+		require.NoError(t, err)
+		defer state.Close()
+
+		scraper := flyscrape.NewScraper()
+		scraper.State = state
+		scraper.Modules = []flyscrape.Module{
+			start,
+			&followlinks.Module{},
+			hook.Module{
+				AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+					return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+						mu.Lock()
+						urls = append(urls, r.URL.String())
+						mu.Unlock()
+
+						if r.URL.String() == "http://www.example.com/" {
+							return flyscrape.MockResponse(200, `<a href="/foo">Foo</a>`)
+						}
+						return flyscrape.MockResponse(200, "")
+					})
+				},
+			},
+		}
+		scraper.Run(context.Background())
+
+		return urls
+	}
+
+	urls := run(&starturl.Module{URL: "http://www.example.com/"})
+	require.ElementsMatch(t, []string{
+		"http://www.example.com/",
+		"http://www.example.com/foo",
+	}, urls)
+
+	urls = run(&starturl.Module{URLs: []string{
+		"http://www.example.com/",
+		"http://www.example.com/bar",
+	}})
+	require.ElementsMatch(t, []string{
+		"http://www.example.com/bar",
+	}, urls)
+}
+
+func TestStateResumeInterrupted(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "test.state")
+
+	type sent struct {
+		Method string
+		URL    string
+		Body   string
+		Depth  int
+		Data   any
+	}
+
+	run := func(ctx context.Context, cancel func()) []sent {
+		var requests []sent
+		var mu sync.Mutex
+
+		state, err := flyscrape.NewState(file)
+		require.NoError(t, err)
+		defer state.Close()
+
+		scraper := flyscrape.NewScraper()
+		scraper.State = state
+		scraper.Options.Workers = 1
+		scraper.ScrapeFunc = func(p flyscrape.ScrapeParams) (any, error) {
+			switch p.URL {
+			case "http://www.example.com/":
+				p.Follow(flyscrape.FollowRequest{
+					URL:    "http://www.example.com/api",
+					Method: "POST",
+					Body:   []byte(`{"page":2}`),
+					Data:   map[string]any{"category": "books"},
+				})
+				p.Follow(flyscrape.FollowRequest{URL: "http://www.example.com/queued"})
+			case "http://www.example.com/api":
+				p.Follow(flyscrape.FollowRequest{URL: "http://www.example.com/"})
+			}
+			return nil, nil
+		}
+		scraper.Modules = []flyscrape.Module{
+			&starturl.Module{URL: "http://www.example.com/"},
+			hook.Module{
+				AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+					return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+						// The first followed request is interrupted midway.
+						if cancel != nil && r.URL.Path != "/" {
+							cancel()
+							<-r.Context().Done()
+							return nil, r.Context().Err()
+						}
+						return flyscrape.MockResponse(200, "")
+					})
+				},
+				BuildRequestFn: func(r *flyscrape.Request) {
+					mu.Lock()
+					requests = append(requests, sent{r.Method, r.URL, string(r.Body), r.Depth, r.Data})
+					mu.Unlock()
+				},
+			},
+		}
+		scraper.Run(ctx)
+
+		return requests
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	requests := run(ctx, cancel)
+	require.Len(t, requests, 2)
+	require.Equal(t, "http://www.example.com/", requests[0].URL)
+
+	// The pending jobs are picked up with all their details, while the
+	// start URL and the links back to it are not fetched again.
+	requests = run(context.Background(), nil)
+	require.ElementsMatch(t, []sent{
+		{"POST", "http://www.example.com/api", `{"page":2}`, 1, map[string]any{"category": "books"}},
+		{"GET", "http://www.example.com/queued", "", 1, nil},
+	}, requests)
+}
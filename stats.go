@@ -0,0 +1,340 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the counters of a run. Counters of a category, like the
+// responses by status class, are named "category.key".
+const (
+	StatPages      = "pages"
+	StatRequests   = "requests"
+	StatBytes      = "bytes"
+	StatCacheHits  = "cacheHits"
+	StatRetries    = "retries"
+	StatDuplicates = "duplicates"
+	StatDropped    = "dropped"
+	StatEnqueued   = "enqueued"
+
+	StatResponses = "responses"
+	StatErrors    = "errors"
+	StatFiltered  = "filtered"
+)
+
+// Names of the durations of a run, which are kept as histograms.
+const (
+	StatRequestDuration = "requestDuration"
+	StatScriptDuration  = "scriptDuration"
+)
+
+// durationBuckets are the upper bounds of the histogram buckets in seconds.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Stats collects the counters of a run. It is safe for concurrent use
+// and a nil *Stats discards all counts.
+type Stats struct {
+	mu         sync.Mutex
+	counters   map[string]int64
+	histograms map[histogramKey]*Histogram
+	start      time.Time
+	end        time.Time
+}
+
+type histogramKey struct {
+	name  string
+	label string
+}
+
+// Histogram counts observed durations in the durationBuckets. Counts
+// are not cumulative, the last one counts the durations above all bounds.
+type Histogram struct {
+	Counts []int64
+	Sum    float64
+	Count  int64
+}
+
+func newStats() *Stats {
+	return &Stats{
+		counters:   map[string]int64{},
+		histograms: map[histogramKey]*Histogram{},
+		start:      time.Now(),
+	}
+}
+
+// Add adds n to the named counter.
+func (s *Stats) Add(name string, n int64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.counters[name] += n
+	s.mu.Unlock()
+}
+
+// Get returns the value of the named counter.
+func (s *Stats) Get(name string) int64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counters[name]
+}
+
+// Observe records a duration in the named histogram. The label tells
+// apart histograms of the same name, like the durations per host.
+func (s *Stats) Observe(name, label string, d time.Duration) {
+	if s == nil {
+		return
+	}
+
+	secs := d.Seconds()
+	i := sort.SearchFloat64s(durationBuckets, secs)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := histogramKey{name, label}
+	h := s.histograms[key]
+	if h == nil {
+		h = &Histogram{Counts: make([]int64, len(durationBuckets)+1)}
+		s.histograms[key] = h
+	}
+	h.Counts[i]++
+	h.Sum += secs
+	h.Count++
+}
+
+// Histograms returns a copy of the named histograms by label.
+func (s *Stats) Histograms(name string) map[string]Histogram {
+	hs := map[string]Histogram{}
+	if s == nil {
+		return hs
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, h := range s.histograms {
+		if key.name == name {
+			hs[key.label] = Histogram{Counts: slices.Clone(h.Counts), Sum: h.Sum, Count: h.Count}
+		}
+	}
+	return hs
+}
+
+// Running reports whether the run is still going.
+func (s *Stats) Running() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.end.IsZero()
+}
+
+func (s *Stats) finish() {
+	s.mu.Lock()
+	s.end = time.Now()
+	s.mu.Unlock()
+}
+
+// Summary is a snapshot of the counters of a run.
+type Summary struct {
+	Pages          int64            `json:"pages"`
+	Requests       int64            `json:"requests"`
+	Responses      map[string]int64 `json:"responses"`
+	Bytes          int64            `json:"bytes"`
+	Errors         map[string]int64 `json:"errors"`
+	Filtered       map[string]int64 `json:"filtered"`
+	CacheHits      int64            `json:"cacheHits"`
+	Retries        int64            `json:"retries"`
+	Duplicates     int64            `json:"duplicates"`
+	Dropped        int64            `json:"dropped"`
+	Enqueued       int64            `json:"enqueued"`
+	Other          map[string]int64 `json:"other,omitempty"`
+	Duration       float64          `json:"duration"`
+	PagesPerSecond float64          `json:"pagesPerSecond"`
+}
+
+// Summary returns a snapshot of the counters. While the run is still
+// going, the duration is the time elapsed so far.
+func (s *Stats) Summary() Summary {
+	sum := Summary{
+		Responses: map[string]int64{},
+		Errors:    map[string]int64{},
+		Filtered:  map[string]int64{},
+	}
+	if s == nil {
+		return sum
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, n := range s.counters {
+		category, key, _ := strings.Cut(name, ".")
+		switch category {
+		case StatPages:
+			sum.Pages = n
+		case StatRequests:
+			sum.Requests = n
+		case StatBytes:
+			sum.Bytes = n
+		case StatCacheHits:
+			sum.CacheHits = n
+		case StatRetries:
+			sum.Retries = n
+		case StatDuplicates:
+			sum.Duplicates = n
+		case StatDropped:
+			sum.Dropped = n
+		case StatEnqueued:
+			sum.Enqueued = n
+		case StatResponses:
+			sum.Responses[key] = n
+		case StatErrors:
+			sum.Errors[key] = n
+		case StatFiltered:
+			sum.Filtered[key] = n
+		default:
+			if sum.Other == nil {
+				sum.Other = map[string]int64{}
+			}
+			sum.Other[name] = n
+		}
+	}
+
+	end := s.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+	duration := end.Sub(s.start)
+	sum.Duration = duration.Seconds()
+	if duration > 0 {
+		sum.PagesPerSecond = float64(sum.Pages) / duration.Seconds()
+	}
+
+	return sum
+}
+
+// String formats the summary for humans.
+func (s Summary) String() string {
+	var b strings.Builder
+
+	duration := time.Duration(s.Duration * float64(time.Second)).Round(time.Millisecond)
+	fmt.Fprintf(&b, "Scraped %d pages in %s (%.1f pages/s)\n", s.Pages, duration, s.PagesPerSecond)
+	if len(s.Responses) > 0 {
+		fmt.Fprintf(&b, "  Requests:   %d (%s)\n", s.Requests, formatCounts(s.Responses))
+	} else {
+		fmt.Fprintf(&b, "  Requests:   %d\n", s.Requests)
+	}
+	fmt.Fprintf(&b, "  Downloaded: %s\n", formatBytes(s.Bytes))
+	if len(s.Errors) > 0 {
+		fmt.Fprintf(&b, "  Errors:     %s\n", formatCounts(s.Errors))
+	}
+	if len(s.Filtered) > 0 {
+		fmt.Fprintf(&b, "  Filtered:   %s\n", formatCounts(s.Filtered))
+	}
+	if s.CacheHits > 0 {
+		fmt.Fprintf(&b, "  Cache hits: %d\n", s.CacheHits)
+	}
+	if s.Retries > 0 {
+		fmt.Fprintf(&b, "  Retries:    %d\n", s.Retries)
+	}
+	if s.Duplicates > 0 {
+		fmt.Fprintf(&b, "  Duplicates: %d\n", s.Duplicates)
+	}
+	if s.Dropped > 0 {
+		fmt.Fprintf(&b, "  Dropped:    %d\n", s.Dropped)
+	}
+	for _, name := range sortedKeys(s.Other) {
+		fmt.Fprintf(&b, "  %s: %d\n", name, s.Other[name])
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func formatCounts(counts map[string]int64) string {
+	var parts []string
+	for _, key := range sortedKeys(counts) {
+		parts = append(parts, fmt.Sprintf("%s %d", key, counts[key]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// statusClass returns the class of a status code, like "2xx".
+func statusClass(code int) string {
+	return fmt.Sprintf("%dxx", code/100)
+}
+
+// errorType classifies a request error for the stats.
+func errorType(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "network"
+	default:
+		return "request"
+	}
+}
+
+// statsTransport counts the requests and responses of all transports
+// and records how long it takes until the response headers arrive.
+func statsTransport(stats *Stats, t http.RoundTripper) http.RoundTripper {
+	return RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		stats.Add(StatRequests, 1)
+
+		start := time.Now()
+		resp, err := t.RoundTrip(r)
+		stats.Observe(StatRequestDuration, r.URL.Hostname(), time.Since(start))
+		if err != nil {
+			stats.Add(StatErrors+"."+errorType(err), 1)
+			return resp, err
+		}
+
+		stats.Add(StatResponses+"."+statusClass(resp.StatusCode), 1)
+		return resp, nil
+	})
+}
@@ -0,0 +1,80 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package flyscrape_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/philippta/flyscrape"
+	"github.com/philippta/flyscrape/modules/followlinks"
+	"github.com/philippta/flyscrape/modules/hook"
+	"github.com/philippta/flyscrape/modules/starturl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	scraper := flyscrape.NewScraper()
+	scraper.ScrapeFunc = func(p flyscrape.ScrapeParams) (any, error) {
+		if strings.HasSuffix(p.URL, "/broken") {
+			return nil, errors.New("broken")
+		}
+		return nil, nil
+	}
+	scraper.Modules = []flyscrape.Module{
+		&starturl.Module{URL: "http://www.example.com/"},
+		&followlinks.Module{},
+		hook.Module{
+			AdaptTransportFn: func(rt http.RoundTripper) http.RoundTripper {
+				return flyscrape.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					switch r.URL.Path {
+					case "/":
+						return flyscrape.MockResponse(200, `
+							<a href="/missing">missing</a>
+							<a href="/down">down</a>
+							<a href="/broken">broken</a>
+							<a href="/private">private</a>`)
+					case "/missing":
+						return flyscrape.MockResponse(404, "")
+					case "/down":
+						return nil, errors.New("connection refused")
+					}
+					return flyscrape.MockResponse(200, "ok")
+				})
+			},
+			ValidateRequestFn: func(r *flyscrape.Request) bool {
+				return !strings.HasSuffix(r.URL, "/private")
+			},
+		},
+	}
+	scraper.Run(context.Background())
+
+	summary := scraper.Stats().Summary()
+	require.Equal(t, int64(4), summary.Pages)
+	require.Equal(t, int64(4), summary.Requests)
+	require.Equal(t, map[string]int64{"2xx": 2, "4xx": 1}, summary.Responses)
+	require.Equal(t, map[string]int64{"request": 1, "script": 1}, summary.Errors)
+	require.Equal(t, map[string]int64{"hook": 1}, summary.Filtered)
+	require.Greater(t, summary.Bytes, int64(0))
+	require.Greater(t, summary.Duration, 0.0)
+	require.Greater(t, summary.PagesPerSecond, 0.0)
+
+	b, err := json.Marshal(summary)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"responses":{"2xx":2,"4xx":1}`)
+
+	require.Contains(t, summary.String(), "Scraped 4 pages")
+}
+
+func TestStatsNil(t *testing.T) {
+	var stats *flyscrape.Stats
+	stats.Add(flyscrape.StatPages, 1)
+	require.Equal(t, int64(0), stats.Get(flyscrape.StatPages))
+	require.Equal(t, int64(0), stats.Summary().Pages)
+}
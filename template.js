@@ -14,6 +14,24 @@ export const config = {
   //     "https://yetanother.com/",
   // ],
 
+  // Specify a file to read the URLs from. "-" for stdin. (default = none)
+  // One URL per line, CSV, or the JSON/NDJSON output of a previous run.
+  // urlsFile: "urls.txt",
+
+  // Specify the CSV column that contains the URLs.      (default = "url")
+  // urlsColumn: "link",
+
+  // Specify sitemaps to take the URLs from.             (default = [])
+  // Sitemap indexes and gzipped sitemaps are supported.
+  // sitemaps: ["https://example.com/sitemap.xml"],
+
+  // Specify the sitemap URLs to scrape as regex.        (default = all)
+  // sitemapURLs: ["/products/"],
+
+  // Only scrape sitemap URLs modified since this date.  (default = all)
+  // Entries without a lastmod date are always scraped.
+  // sitemapSince: "2024-01-01",
+
   // Specify how deep links should be followed.          (default = 0, no follow)
   // depth: 5,                        
 
@@ -31,13 +49,74 @@ export const config = {
 
   // Specify the blocked URLs as regex.                  (default = none)
   // blockedURLs: ["/admin"],                 
+
+  // Respect robots.txt, including the Crawl-delay.      (default = false)
+  // robots: true,
+
+  // Specify the user-agent token for robots.txt rules.  (default = "flyscrape")
+  // robotsUserAgent: "flyscrape",
  
-  // Specify the rate in requests per minute.            (default = no rate limit)
+  // Specify the rate in requests per minute per host.   (default = no rate limit)
+  // Can be set per host: { default: 60, "api.example.com": 600 }
   // rate: 60,                       
 
-  // Specify the number of concurrent requests.          (default = no limit)
+  // Specify the number of concurrent requests per host. (default = no limit)
+  // Can be set per host like the rate.
   // concurrency: 1,                       
 
+  // Specify the minimum delay between requests to the   (default = no delay)
+  // same host in milliseconds.
+  // Can be set per host like the rate.
+  // delay: 1000,
+
+  // Specify whether the rate, concurrency and delay     (default = "host")
+  // apply per host or per registered domain.
+  // Options: "host" | "domain"
+  // rateLimitBy: "host",
+
+  // Specify the number of URLs processed in parallel.   (default = 500)
+  // workers: 100,
+
+  // Specify the number of pages extracted in parallel.   (default = number of CPUs)
+  // Each runs its own copy of the script: top-level code, like requests
+  // or downloads, runs once per copy and top-level variables are not
+  // shared between pages. Use 1 to share them.
+  // runtimes: 4,
+
+  // Specify the number of queued URLs kept in memory.   (default = 100000)
+  // Any URLs beyond that are queued on disk.
+  // queueSize: 100000,
+
+  // Specify the order in which URLs are scraped.        (default = "bfs")
+  // Options: "bfs" | "dfs" | "priority"
+  // "priority" requires an exported priority function.
+  // frontier: "bfs",
+
+  // Specify query parameters to ignore when checking    (default = none)
+  // whether a URL was already scraped. Supports "*".
+  // stripParams: ["utm_*", "sessionid"],
+
+  // Skip pages whose content was already scraped.       (default = false)
+  // Their data is not output and their links are not followed.
+  // dedup: true,
+
+  // Specify how many of the 64 bits of a page's text    (default = 3)
+  // fingerprint may differ to count as a duplicate.
+  // 0 only skips pages with the exact same content.
+  // dedupDistance: 3,
+
+  // Stop after this many pages have been scraped.       (default = no limit)
+  // maxPages: 1000,
+
+  // Stop after this many bytes have been downloaded.    (default = no limit)
+  // maxBytes: 100000000,
+
+  // Stop after this duration, e.g. "30m" or "2h".       (default = no limit)
+  // maxDuration: "1h",
+
+  // Specify the maximum number of pages per host.       (default = no limit)
+  // maxPagesPerDomain: 100,
+
   // Specify a single HTTP(S) proxy URL.                 (default = no proxy)
   // Note: Not compatible with browser mode.
   // proxy: "http://someproxy.com:8043",
@@ -85,3 +164,10 @@ export default function({ doc, absoluteURL }) {
     },
   };
 }
+
+// Optional lifecycle hooks, see the README.
+// export async function setup() {}
+// export async function teardown(summary, setup) {}
+// export function onError({ url, status, error, attempt, meta, setup, retry }) {}
+// export async function beforeRequest({ method, url, headers, body, depth, meta, referrer }) {}
+// export async function afterResponse({ url, status, headers, body, meta }) {}
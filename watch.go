@@ -5,6 +5,7 @@
 package flyscrape
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -15,7 +16,7 @@ import (
 
 var StopWatch = errors.New("stop watch")
 
-func Watch(path string, fn func(string) error) error {
+func Watch(ctx context.Context, path string, fn func(string) error) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return fmt.Errorf("creating file watcher: %w", err)
@@ -40,6 +41,8 @@ func Watch(path string, fn func(string) error) error {
 
 	for {
 		select {
+		case <-ctx.Done():
+			return nil
 		case e, ok := <-watcher.Events:
 			if !ok {
 				return nil
@@ -5,6 +5,7 @@
 package flyscrape_test
 
 import (
+	"context"
 	"os"
 	"testing"
 	"time"
@@ -22,7 +23,7 @@ func TestWatch(t *testing.T) {
 	done := make(chan struct{})
 
 	go func() {
-		err := flyscrape.Watch(f.Name(), func(s string) error {
+		err := flyscrape.Watch(context.Background(), f.Name(), func(s string) error {
 			calls++
 			if calls == 1 {
 				require.Equal(t, "test 1", s)